@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// AutoTuneHealthy is how many healthy checks in a row let -auto-tune add a
+// worker.
+const AutoTuneHealthy = 5
+
+// AutoTuneSlow is the duration from which a check no longer counts as
+// healthy: the registry is struggling, adding workers would only queue more.
+const AutoTuneSlow = 3 * time.Second
+
+// autoTuner limits how many of the workers of a batch check at once. It
+// starts with one and adds one after every AutoTuneHealthy fast successes,
+// up to max, and halves the number on a captcha, a timeout or a failure to
+// reach the registry. The rate limiter still spaces the requests, so the
+// tuner only ever gets closer to -rate, never past it. A nil autoTuner
+// doesn't limit anything.
+type autoTuner struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	limit   int
+	max     int
+	active  int
+	healthy int
+}
+
+var tuner *autoTuner
+
+func newAutoTuner(max int) *autoTuner {
+	t := &autoTuner{limit: 1, max: max}
+	t.changed = sync.NewCond(&t.mu)
+	return t
+}
+
+// acquire blocks until one more worker may start a check.
+func (t *autoTuner) acquire() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for t.active >= t.limit {
+		t.changed.Wait()
+	}
+	t.active++
+}
+
+// release ends a check that took elapsed and failed with err, if not nil,
+// and adjusts the limit by its outcome.
+func (t *autoTuner) release(elapsed time.Duration, err error) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.active--
+
+	switch {
+	case isTransient(err), errors.Is(err, ErrTimedOut), errors.Is(err, ErrCaptchaRequired):
+		t.backOff()
+	case err == nil && elapsed < AutoTuneSlow:
+		if t.healthy++; t.healthy >= AutoTuneHealthy && t.limit < t.max {
+			t.limit++
+			t.healthy = 0
+			log.Printf("Auto-tune: checking up to %d at once", t.limit)
+		}
+	default:
+		t.healthy = 0
+	}
+
+	t.changed.Broadcast()
+}
+
+// throttled backs off when a check ran into a captcha or the throttle page,
+// even one it got past.
+func (t *autoTuner) throttled() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.backOff()
+}
+
+func (t *autoTuner) backOff() {
+	t.healthy = 0
+	if t.limit > 1 {
+		t.limit /= 2
+		log.Printf("Auto-tune: backing off to %d at once", t.limit)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxParallelCaptchas is how many workers may be blocked on a captcha before
+// new checks are held back.
+const MaxParallelCaptchas = 2
+
+// captchaGuard coordinates the workers that hit a captcha. Prompts are shown
+// one at a time, a worker whose captcha was solved by an earlier prompt just
+// re-fetches, and no new checks start while too many workers are blocked.
+type captchaGuard struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	prompt  sync.Mutex
+	blocked int
+	solved  int
+	limit   int
+	warned  bool
+
+	// notified is when the webhook was last called, calls those in flight.
+	notified time.Time
+	calls    sync.WaitGroup
+}
+
+func newCaptchaGuard(limit int) *captchaGuard {
+	g := &captchaGuard{limit: limit}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+var captchas = newCaptchaGuard(MaxParallelCaptchas)
+
+// solve asks the user to solve the captcha at query, unless another prompt
+// finished while this one was queued.
+func (g *captchaGuard) solve(query string) error {
+	g.mu.Lock()
+	g.blocked++
+	generation := g.solved
+	if g.blocked > g.limit && !g.warned {
+		g.warned = true
+		log.Printf("%d checks hit a captcha, the registry is likely rate-limiting; pausing new checks", g.blocked)
+	}
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		g.blocked--
+		if g.blocked == 0 {
+			g.warned = false
+		}
+		g.cond.Broadcast()
+		g.mu.Unlock()
+	}()
+
+	g.prompt.Lock()
+	defer g.prompt.Unlock()
+
+	g.mu.Lock()
+	alreadySolved := g.solved != generation
+	g.mu.Unlock()
+
+	if alreadySolved {
+		return nil
+	}
+
+	message := captchaMessage(query)
+	g.notify(query, message)
+
+	if !isTerminal(os.Stdin) || isDevNull(os.Stdin) {
+		return fmt.Errorf("%w, solve it at %s", ErrCaptchaRequired, query)
+	}
+
+	fmt.Fprint(os.Stderr, message)
+	if !waitForUser() {
+		return fmt.Errorf("%w, solve it at %s", ErrCaptchaRequired, query)
+	}
+
+	g.mu.Lock()
+	g.solved++
+	g.mu.Unlock()
+
+	return nil
+}
+
+// captchaPrompt overrides the captcha prompt of the language, see
+// captchaMessage.
+var captchaPrompt string
+
+// captchaMessage fills in query for {url} in the captcha prompt.
+func captchaMessage(query string) string {
+	template := lang.captcha
+	if captchaPrompt != "" {
+		template = captchaPrompt
+	}
+	return strings.ReplaceAll(template, "{url}", query)
+}
+
+// CaptchaWebhookInterval is the least time between two calls of the
+// -captcha-webhook, so that a batch running into the captcha doesn't call
+// it for every domain.
+const CaptchaWebhookInterval = 15 * time.Minute
+
+// captchaWebhook is called with a JSON POST whenever a check hits a captcha,
+// so that an operator of an unattended run gets to solve it.
+var captchaWebhook string
+
+// notify calls the captchaWebhook in the background, at most once per
+// CaptchaWebhookInterval. Failures are only logged.
+func (g *captchaGuard) notify(query, message string) {
+	if captchaWebhook == "" {
+		return
+	}
+
+	g.mu.Lock()
+	if !g.notified.IsZero() && time.Since(g.notified) < CaptchaWebhookInterval {
+		g.mu.Unlock()
+		return
+	}
+	g.notified = time.Now()
+	g.mu.Unlock()
+
+	body, _ := json.Marshal(struct {
+		Event   string `json:"event"`
+		URL     string `json:"url"`
+		Message string `json:"message"`
+	}{"captcha", query, message})
+
+	g.calls.Add(1)
+	go func() {
+		defer g.calls.Done()
+		// Not the registry's client: it would count the call as a query.
+		client := &http.Client{Timeout: requestTimeout}
+		response, err := client.Post(captchaWebhook, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Captcha webhook failed: %v", err)
+			return
+		}
+		response.Body.Close()
+
+		if response.StatusCode >= 300 {
+			log.Printf("Captcha webhook failed: returned code %d", response.StatusCode)
+		}
+	}()
+}
+
+// flush waits for the webhook calls in flight, before the process exits.
+func (g *captchaGuard) flush() {
+	g.calls.Wait()
+}
+
+// wait holds a new check back while more than the limit of workers are
+// blocked on a captcha.
+func (g *captchaGuard) wait() {
+	g.mu.Lock()
+	for g.blocked > g.limit {
+		g.cond.Wait()
+	}
+	g.mu.Unlock()
+}
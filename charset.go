@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// charsetTables map the bytes 0x80 to 0xff of the single-byte charsets Czech
+// pages may come in to their runes; the lower half is ASCII in all of them.
+var charsetTables = map[string][]rune{
+	"windows-1250": []rune(windows1250),
+	"iso-8859-2":   []rune(iso88592),
+}
+
+// charsetAliases are the other names of the charsets pages declare.
+var charsetAliases = map[string]string{
+	"utf8":       "utf-8",
+	"us-ascii":   "utf-8",
+	"ascii":      "utf-8",
+	"cp1250":     "windows-1250",
+	"x-cp1250":   "windows-1250",
+	"latin2":     "iso-8859-2",
+	"iso8859-2":  "iso-8859-2",
+	"iso_8859-2": "iso-8859-2",
+}
+
+// metaCharset finds the charset declared by a meta tag, either
+// <meta charset="..."> or the content type of <meta http-equiv>.
+var metaCharset = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?([\w.:-]+)`)
+
+// MetaCharsetScope is how far into a page the meta tag is looked for; HTML
+// requires it within the first 1024 bytes.
+const MetaCharsetScope = 1024
+
+// pageCharset returns the lowercase charset of a page: the one of the
+// Content-Type header if it has any, else the one of a meta tag, else "".
+func pageCharset(contentType, content string) string {
+	name := ""
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		name = params["charset"]
+	}
+	if name == "" {
+		if match := metaCharset.FindStringSubmatch(content[:min(len(content), MetaCharsetScope)]); match != nil {
+			name = match[1]
+		}
+	}
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := charsetAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// toUTF8 decodes content from charset, "" meaning UTF-8, so that the
+// haystacks match whatever encoding the page came in. A UTF-8 byte order mark
+// is dropped.
+func toUTF8(content, charset string) (string, error) {
+	content = strings.TrimPrefix(content, "\uFEFF")
+
+	if charset == "" || charset == "utf-8" {
+		return content, nil
+	}
+
+	table, ok := charsetTables[charset]
+	if !ok {
+		return "", fmt.Errorf("unsupported charset %q", charset)
+	}
+
+	var decoded strings.Builder
+	decoded.Grow(len(content) + len(content)/8)
+	for i := 0; i < len(content); i++ {
+		if b := content[i]; b < utf8.RuneSelf {
+			decoded.WriteByte(b)
+		} else {
+			decoded.WriteRune(table[b-0x80])
+		}
+	}
+
+	return decoded.String(), nil
+}
+
+// windows1250 is the upper half of windows-1250, the Central European charset
+// of Windows.
+const windows1250 = "" +
+	"\u20ac\ufffd\u201a\ufffd\u201e\u2026\u2020\u2021\ufffd\u2030\u0160\u2039\u015a\u0164\u017d\u0179" +
+	"\ufffd\u2018\u2019\u201c\u201d\u2022\u2013\u2014\ufffd\u2122\u0161\u203a\u015b\u0165\u017e\u017a" +
+	"\u00a0\u02c7\u02d8\u0141\u00a4\u0104\u00a6\u00a7\u00a8\u00a9\u015e\u00ab\u00ac\u00ad\u00ae\u017b" +
+	"\u00b0\u00b1\u02db\u0142\u00b4\u00b5\u00b6\u00b7\u00b8\u0105\u015f\u00bb\u013d\u02dd\u013e\u017c" +
+	"\u0154\u00c1\u00c2\u0102\u00c4\u0139\u0106\u00c7\u010c\u00c9\u0118\u00cb\u011a\u00cd\u00ce\u010e" +
+	"\u0110\u0143\u0147\u00d3\u00d4\u0150\u00d6\u00d7\u0158\u016e\u00da\u0170\u00dc\u00dd\u0162\u00df" +
+	"\u0155\u00e1\u00e2\u0103\u00e4\u013a\u0107\u00e7\u010d\u00e9\u0119\u00eb\u011b\u00ed\u00ee\u010f" +
+	"\u0111\u0144\u0148\u00f3\u00f4\u0151\u00f6\u00f7\u0159\u016f\u00fa\u0171\u00fc\u00fd\u0163\u02d9"
+
+// iso88592 is the upper half of ISO 8859-2, Latin-2.
+const iso88592 = "" +
+	"\u0080\u0081\u0082\u0083\u0084\u0085\u0086\u0087\u0088\u0089\u008a\u008b\u008c\u008d\u008e\u008f" +
+	"\u0090\u0091\u0092\u0093\u0094\u0095\u0096\u0097\u0098\u0099\u009a\u009b\u009c\u009d\u009e\u009f" +
+	"\u00a0\u0104\u02d8\u0141\u00a4\u013d\u015a\u00a7\u00a8\u0160\u015e\u0164\u0179\u00ad\u017d\u017b" +
+	"\u00b0\u0105\u02db\u0142\u00b4\u013e\u015b\u02c7\u00b8\u0161\u015f\u0165\u017a\u02dd\u017e\u017c" +
+	"\u0154\u00c1\u00c2\u0102\u00c4\u0139\u0106\u00c7\u010c\u00c9\u0118\u00cb\u011a\u00cd\u00ce\u010e" +
+	"\u0110\u0143\u0147\u00d3\u00d4\u0150\u00d6\u00d7\u0158\u016e\u00da\u0170\u00dc\u00dd\u0162\u00df" +
+	"\u0155\u00e1\u00e2\u0103\u00e4\u013a\u0107\u00e7\u010d\u00e9\u0119\u00eb\u011b\u00ed\u00ee\u010f" +
+	"\u0111\u0144\u0148\u00f3\u00f4\u0151\u00f6\u00f7\u0159\u016f\u00fa\u0171\u00fc\u00fd\u0163\u02d9"
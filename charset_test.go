@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// The testdata/<charset>.txt files hold every byte the charset defines from
+// 0x80 up, the .utf8.txt ones the same characters as decoded by Python's
+// codecs, against which the tables were checked.
+func TestCharsetTables(t *testing.T) {
+	for charset := range charsetTables {
+		t.Run(charset, func(t *testing.T) {
+			decoded, err := toUTF8(readTestdata(t, charset+".txt"), charset)
+			if want := readTestdata(t, charset+".utf8.txt"); err != nil || decoded != want {
+				t.Errorf("toUTF8 = %q, %v, want %q", decoded, err, want)
+			}
+		})
+	}
+}
+
+func TestPageCharset(t *testing.T) {
+	for _, tc := range []struct {
+		contentType, content, want string
+	}{
+		{"text/html; charset=Windows-1250", "", "windows-1250"},
+		{"text/html; charset=cp1250", "", "windows-1250"},
+		{"text/html", `<meta charset="ISO-8859-2">`, "iso-8859-2"},
+		{"text/html; charset=utf-8", `<meta charset="windows-1250">`, "utf-8"},
+		{"", `<meta http-equiv="Content-Type" content="text/html; charset=latin2">`, "iso-8859-2"},
+		{"text/html", "<html>", ""},
+	} {
+		if got := pageCharset(tc.contentType, tc.content); got != tc.want {
+			t.Errorf("pageCharset(%q, %q) = %q, want %q", tc.contentType, tc.content, got, tc.want)
+		}
+	}
+}
+
+func TestWindows1250Page(t *testing.T) {
+	page := readTestdata(t, "cp1250.cz.html")
+	want := []string{
+		"Doména je blokována",
+		"Není povolena změna určeného registrátora",
+		"Žluťoučký kůň úpěl ďábelské ódy",
+	}
+
+	// Declared by the header, and only by the meta tag of the page.
+	for _, contentType := range []string{"text/html; charset=Windows-1250", "text/html"} {
+		serveRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Write([]byte(page))
+		})
+
+		result, err := checkHTTP(context.Background(), "cp1250.cz")
+		if err != nil {
+			t.Fatalf("Content-Type %s: %v", contentType, err)
+		}
+		if !slices.Equal(result.Statuses, want) {
+			t.Errorf("Content-Type %s: Statuses = %q, want %q", contentType, result.Statuses, want)
+		}
+	}
+}
+
+func TestWindows1250Captcha(t *testing.T) {
+	content, err := toUTF8(readTestdata(t, "cp1250captcha.cz.html"), "windows-1250")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if marker, at := findCaptcha(content); marker != HaystackCaptcha || at < 0 {
+		t.Errorf("findCaptcha of the decoded page = %q, %d, want %q", marker, at, HaystackCaptcha)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+// Checker checks domains like CheckURLContext, with the HTTP client of its
+// options instead of the one built from the command line flags. Being in
+// package main, the checker isn't importable; this is for the tests running
+// checks against a stand-in client.
+type Checker struct {
+	client *http.Client
+}
+
+// Option configures a Checker.
+type Option func(*Checker)
+
+// WithHTTPClient makes the checker send its http and rdap queries with
+// client.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Checker) {
+		c.client = client
+	}
+}
+
+// WithTransport makes the checker send its http and rdap queries through
+// transport, with the request timeout of the package.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Checker) {
+		c.client = &http.Client{Transport: transport, Timeout: requestTimeout}
+	}
+}
+
+// NewChecker returns a Checker with opts applied. Without WithHTTPClient or
+// WithTransport it uses the package's client. A client of the options is
+// copied and its transport counted in registryRequests, like the package's.
+func NewChecker(opts ...Option) *Checker {
+	c := &Checker{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.client != nil {
+		client := *c.client
+		if client.Transport == nil {
+			client.Transport = http.DefaultTransport
+		}
+		if _, ok := client.Transport.(countingTransport); !ok {
+			client.Transport = countingTransport{client.Transport}
+		}
+		c.client = &client
+	}
+
+	return c
+}
+
+// Check checks url, see CheckURLContext.
+func (c *Checker) Check(ctx context.Context, url string) (*CheckResult, error) {
+	if c.client != nil {
+		ctx = context.WithValue(ctx, clientKey{}, c.client)
+	}
+	return CheckURLContext(ctx, url)
+}
+
+type clientKey struct{}
+
+// clientFor returns the client to send the requests of ctx with: the one of
+// the Checker making them, or httpClient.
+func clientFor(ctx context.Context) *http.Client {
+	if client, ok := ctx.Value(clientKey{}).(*http.Client); ok {
+		return client
+	}
+	return httpClient
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc is an http.RoundTripper calling itself.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func TestCheckerUsesItsTransport(t *testing.T) {
+	override(t, &limiter, newRateLimiter(1000))
+	page := readTestdata(t, "twice.cz.html")
+
+	var asked []string
+	checker := NewChecker(WithTransport(roundTripFunc(func(request *http.Request) (*http.Response, error) {
+		asked = append(asked, request.URL.Path)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/html; charset=utf-8"}},
+			Body:       io.NopCloser(strings.NewReader(page)),
+			Request:    request,
+		}, nil
+	})))
+
+	before := registryRequests.Load()
+	result, err := checker.Check(context.Background(), "www.twice.cz")
+	if err != nil || result.RawExpiration != "15.03.2034" {
+		t.Fatalf("Check = %+v, %v, want the page of the transport", result, err)
+	}
+	if len(asked) != 1 || asked[0] != "/whois/domain/twice.cz" {
+		t.Errorf("the transport was asked for %q, want the page of twice.cz once", asked)
+	}
+	// -stats and -summary-json count the requests of the transport too.
+	if counted := registryRequests.Load() - before; counted != 1 {
+		t.Errorf("registryRequests grew by %d, want 1", counted)
+	}
+}
+
+func TestCheckerCountsItsClientRequests(t *testing.T) {
+	serveRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(readTestdata(t, "twice.cz.html")))
+	})
+	client := &http.Client{}
+
+	before := registryRequests.Load()
+	if _, err := NewChecker(WithHTTPClient(client)).Check(context.Background(), "twice.cz"); err != nil {
+		t.Fatal(err)
+	}
+	if counted := registryRequests.Load() - before; counted != 1 {
+		t.Errorf("registryRequests grew by %d, want 1", counted)
+	}
+	if client.Transport != nil {
+		t.Errorf("NewChecker set the transport of the caller's client to %T, want it left nil", client.Transport)
+	}
+}
+
+func TestCheckDomainsCollectsFailures(t *testing.T) {
+	override(t, &retries, 0)
+	serveRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/whois/domain/down.cz":
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		case "/whois/domain/free.cz":
+			w.Write([]byte(readTestdata(t, "free.cz.html")))
+		default:
+			w.Write([]byte(readTestdata(t, "twice.cz.html")))
+		}
+	})
+
+	results, err := CheckDomains([]string{"twice.cz", "down.cz", "free.cz", "-bad.cz"})
+
+	if len(results) != 2 || results[0].URL != "twice.cz" || results[1].URL != "free.cz" {
+		t.Errorf("CheckDomains results = %+v, want twice.cz and free.cz", results)
+	}
+
+	var batch *BatchError
+	if !errors.As(err, &batch) || len(batch.Errors) != 2 {
+		t.Fatalf("CheckDomains error = %v, want a BatchError of the 2 failures", err)
+	}
+	if !errors.Is(err, ErrServiceUnavailable) || !errors.Is(err, ErrInvalidDomain) {
+		t.Errorf("errors.Is doesn't find the sentinels of %v", err)
+	}
+
+	var domainErr *DomainError
+	if !errors.As(batch.Errors[1], &domainErr) || domainErr.Domain != "-bad.cz" {
+		t.Errorf("second failure = %v, want the DomainError of -bad.cz", batch.Errors[1])
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// registry describes the checker of one TLD. Its public suffixes are the
+// ones names are registered under, like the TLD itself or co.uk; without the
+// public suffix list at hand every registry lists its own.
+type registry struct {
+	description string
+	methods     []string
+	suffixes    []string
+}
+
+// supportedTLDs lists the TLDs there's a registry checker for.
+var supportedTLDs = map[string]registry{
+	"cz": {
+		description: "CZ.NIC, www.nic.cz",
+		methods:     []string{"http", "whois43", "rdap"},
+		suffixes:    []string{"cz"},
+	},
+}
+
+// registrable returns the registrable part of host, its longest public suffix
+// and the label before it. A host without more labels is returned as is.
+func (r registry) registrable(host string) string {
+	labels := strings.Split(host, ".")
+	longest := 0
+
+	for _, suffix := range r.suffixes {
+		if n := strings.Count(suffix, ".") + 1; n > longest && n < len(labels) && strings.HasSuffix(host, "."+suffix) {
+			longest = n
+		}
+	}
+
+	if longest == 0 {
+		return host
+	}
+
+	return strings.Join(labels[len(labels)-longest-1:], ".")
+}
+
+// listCheckers prints the supported TLDs with their registry and methods, the
+// default method first.
+func listCheckers(w io.Writer) {
+	tlds := make([]string, 0, len(supportedTLDs))
+	for tld := range supportedTLDs {
+		tlds = append(tlds, tld)
+	}
+	sort.Strings(tlds)
+
+	for _, tld := range tlds {
+		r := supportedTLDs[tld]
+		fmt.Fprintf(w, ".%s\t%s\t%s\n", tld, r.description, strings.Join(r.methods, ", "))
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// readResults reads a previous jsonl output (or a JSON array of results) into
+// a map keyed by the normalized domain. Failed checks are left out.
+func readResults(path string) (map[string]jsonResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	results := map[string]jsonResult{}
+	add := func(r jsonResult) {
+		if r.Error == "" && r.Domain != "" {
+			results[r.Domain] = r
+		}
+	}
+
+	if first, _ := reader.Peek(1); len(first) > 0 && first[0] == '[' {
+		var all []jsonResult
+		if err := json.NewDecoder(reader).Decode(&all); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		for _, r := range all {
+			add(r)
+		}
+		return results, nil
+	}
+
+	scanner := bufio.NewScanner(reader)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var r jsonResult
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		add(r)
+	}
+
+	return results, scanner.Err()
+}
+
+// describeChange tells what changed between two results of a domain, or
+// returns an empty string if nothing did.
+func describeChange(previous, current jsonResult) string {
+	switch {
+	case previous.Reserved != current.Reserved && current.Reserved:
+		return "Newly reserved"
+	case previous.Reserved != current.Reserved:
+		return "No longer reserved"
+	case !previous.Free && current.Free:
+		return "Newly free"
+	case previous.Free && !current.Free:
+		return "Newly registered, expires " + current.Expiration
+	case previous.Expiration == current.Expiration:
+		return ""
+	case previous.Expiration < current.Expiration:
+		return "Expiration extended from " + previous.Expiration + " to " + current.Expiration
+	default:
+		return "Expiration moved from " + previous.Expiration + " to " + current.Expiration
+	}
+}
+
+// reportChanges writes the domains whose status or expiration differs from
+// the previous results and returns how many there were. Domains missing from
+// either side aren't changes.
+func reportChanges(w io.Writer, previous map[string]jsonResult, outcomes []outcome) int {
+	changes := 0
+
+	for _, o := range outcomes {
+		if o.err != nil {
+			continue
+		}
+
+		current := toJSON(o.result)
+		old, ok := previous[current.Domain]
+		if !ok {
+			continue
+		}
+
+		if change := describeChange(old, current); change != "" {
+			changes++
+			if outputFormat != "text" {
+				writeJSONLine(w, map[string]string{"domain": current.Domain, "change": change})
+			} else {
+				fmt.Fprintf(w, "%s\t%s\n", current.Domain, change)
+			}
+		}
+	}
+
+	return changes
+}
+
+// changeExitCode maps the exit code of a -compare-with run, without the
+// changes, to the one of -only-changed-exit.
+func changeExitCode(code int, changed bool) int {
+	switch {
+	case code != ExitOK:
+		return ExitRunError
+	case changed:
+		return ExitChangesFound
+	default:
+		return ExitNoChanges
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// printCompletion writes a completion script for the registered flags.
+func printCompletion(w io.Writer, shell string) error {
+	name := filepath.Base(os.Args[0])
+	var flags []*flag.Flag
+	flag.VisitAll(func(f *flag.Flag) {
+		flags = append(flags, f)
+	})
+
+	switch shell {
+	case "bash":
+		words := make([]string, len(flags))
+		for i, f := range flags {
+			words[i] = "-" + f.Name
+		}
+
+		fmt.Fprintf(w, "_%s() {\n", identifier(name))
+		fmt.Fprintf(w, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+		fmt.Fprintf(w, "\tCOMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(words, " "))
+		fmt.Fprintf(w, "}\n")
+		fmt.Fprintf(w, "complete -F _%s %s\n", identifier(name), name)
+	case "zsh":
+		fmt.Fprintf(w, "#compdef %s\n\n_arguments \\\n", name)
+		for _, f := range flags {
+			_, usage := flag.UnquoteUsage(f)
+			usage = strings.NewReplacer("[", "(", "]", ")", "'", "", ":", " ").Replace(usage)
+			fmt.Fprintf(w, "\t'-%s[%s]' \\\n", f.Name, usage)
+		}
+		fmt.Fprintf(w, "\t'*:domain:'\n")
+	case "fish":
+		for _, f := range flags {
+			_, usage := flag.UnquoteUsage(f)
+			usage = strings.Replace(usage, "'", "\\'", -1)
+			fmt.Fprintf(w, "complete -c %s -o %s -d '%s'\n", name, f.Name, usage)
+		}
+	default:
+		return errors.New("Unsupported shell " + shell + ", use bash, zsh or fish")
+	}
+
+	return nil
+}
+
+func identifier(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '.' {
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// savedCookie is a cookie as stored in the cookie file, with the URL it was
+// set by.
+type savedCookie struct {
+	URL    string
+	Cookie *http.Cookie
+}
+
+// persistentJar is a cookie jar that writes the cookies to a file after every
+// change, so a captcha solved in one run stays solved in the next as long as
+// the registry keeps honoring the session.
+type persistentJar struct {
+	*cookiejar.Jar
+	mu      sync.Mutex
+	path    string
+	cookies map[string]savedCookie
+}
+
+func cookieFilePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "czdomain", "cookies.json"), nil
+}
+
+// loadCookieJar opens the jar stored at path. A missing file gives an empty
+// jar, expired cookies are dropped.
+func loadCookieJar(path string) (*persistentJar, error) {
+	inner, _ := cookiejar.New(nil)
+	jar := &persistentJar{Jar: inner, path: path, cookies: map[string]savedCookie{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return jar, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var saved []savedCookie
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+
+	for _, c := range saved {
+		u, err := url.Parse(c.URL)
+		if err != nil || c.Cookie == nil || (!c.Cookie.Expires.IsZero() && c.Cookie.Expires.Before(time.Now())) {
+			continue
+		}
+
+		jar.cookies[cookieKey(u, c.Cookie)] = c
+		inner.SetCookies(u, []*http.Cookie{c.Cookie})
+	}
+
+	return jar, nil
+}
+
+func cookieKey(u *url.URL, c *http.Cookie) string {
+	return u.Host + ";" + c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+// SetCookies stores the cookies in the jar and in the file.
+func (j *persistentJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	origin := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}
+	for _, c := range cookies {
+		c := *c
+		if c.MaxAge > 0 {
+			c.Expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+			c.MaxAge = 0
+		}
+
+		key := cookieKey(origin, &c)
+		if c.MaxAge < 0 || (!c.Expires.IsZero() && c.Expires.Before(time.Now())) {
+			delete(j.cookies, key)
+		} else {
+			j.cookies[key] = savedCookie{URL: origin.String(), Cookie: &c}
+		}
+	}
+
+	if err := j.save(); err != nil {
+		log.Printf("Can't save the cookies: %v", err)
+	}
+}
+
+// save writes the cookies next to the file and renames the copy over it, so
+// an interrupted run never leaves a truncated file behind.
+func (j *persistentJar) save() error {
+	saved := make([]savedCookie, 0, len(j.cookies))
+	for _, c := range j.cookies {
+		saved = append(saved, c)
+	}
+
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0700); err != nil {
+		return err
+	}
+
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, j.path)
+}
@@ -3,68 +3,608 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"html"
+	"io"
 	"log"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-// BaseURL Url to send queries to.
-const BaseURL = "https://www.nic.cz/whois/domain/"
+// BaseURL is the registry host to send queries to.
+const BaseURL = "https://www.nic.cz"
+
+// WhoisPath is the path of the WHOIS page. The domain is appended to it, or
+// substituted for a %s placeholder if there is one.
+const WhoisPath = "/whois/domain/"
 
 // Politeness factor. Don't be evil.
 const Politeness = 1 * time.Second
 
+// ProtectionDays is the default of -protection-days, how long after the
+// expiration CZ.NIC deletes a domain that wasn't renewed.
+const ProtectionDays = 61
+
+var protectionDays = ProtectionDays
+
+// DelayOnError is the default of -delay-on-error, the extra delay between
+// requests added after each failed domain and taken off after each success.
+const DelayOnError = time.Second
+
+// MaxErrorDelay is the default of -max-error-delay, the most the failures
+// can add to the delay between requests.
+const MaxErrorDelay = 30 * time.Second
+
+// limiter bounds the request rate to the registry, Politeness by default.
+var limiter = newRateLimiter(float64(time.Second) / float64(Politeness))
+
 // HaystackCaptcha means the captcha is displayed.
 const HaystackCaptcha = "Kontrolní kód"
 
+// CaptchaMarkers are the defaults of -captcha-markers: besides the text
+// captcha label, the containers of common JavaScript challenges.
+var CaptchaMarkers = []string{HaystackCaptcha, "g-recaptcha", "h-captcha", "cf-turnstile"}
+
+var captchaMarkers = CaptchaMarkers
+
+// MaintenanceMarkers are the phrases of the pages nic.cz serves during
+// maintenance or an outage, in Czech and English.
+var MaintenanceMarkers = []string{
+	"Probíhá údržba",
+	"probíhá plánovaná údržba",
+	"Služba je dočasně nedostupná",
+	"Omlouváme se, služba není dostupná",
+	"Scheduled maintenance",
+	"under maintenance",
+	"Service temporarily unavailable",
+	"Service Unavailable",
+}
+
+// ThrottleMarkers are the phrases of the page nic.cz shows instead of the
+// result to a client sending too many queries. Unlike a captcha there's
+// nothing to solve, only to wait.
+var ThrottleMarkers = []string{
+	"Příliš mnoho dotazů",
+	"Překročili jste povolený počet dotazů",
+	"Too many requests",
+	"Query limit exceeded",
+}
+
 // HaystackFree means the domain is free to register.
 const HaystackFree = "nebyla nalezena"
 
+// HaystackReserved means the registry reserved or blocked the name, so it's
+// neither registered nor free to register.
+const HaystackReserved = "nelze registrovat"
+
 // HaystackExpiration is used to find the expiration date offset.
 const HaystackExpiration = "Datum expirace"
 
+// HaystackStatus labels the table cell listing the domain's status flags.
+const HaystackStatus = "Stav"
+
 // ExpirationOffset = (the start of the date) - HaystackExpiration
 const ExpirationOffset = 72
 
+// pageHaystacks are the phrases the http method reads a page by. An -env may
+// replace them for a registry whose pages differ, like a staging one.
+type pageHaystacks struct {
+	Free             string `json:"free"`
+	Reserved         string `json:"reserved"`
+	Expiration       string `json:"expiration"`
+	Status           string `json:"status"`
+	ExpirationOffset int    `json:"expiration_offset"`
+}
+
+var haystacks = pageHaystacks{
+	Free:             HaystackFree,
+	Reserved:         HaystackReserved,
+	Expiration:       HaystackExpiration,
+	Status:           HaystackStatus,
+	ExpirationOffset: ExpirationOffset,
+}
+
 // ExpirationLength is length of the expiration date format.
 const ExpirationLength = 10
 
+// MaxBodySize caps the size of a response. WHOIS pages are a few kB.
+const MaxBodySize = 4 << 20
+
+var maxBodySize int64 = MaxBodySize
+
+// httpClient sends the requests of the http and rdap methods, unless a
+// Checker has its own; main replaces it with one honoring the timeout flags.
+var httpClient = &http.Client{}
+
+// SessionCookieEnv names the environment variable -session-cookie defaults
+// to, which keeps the value out of the process list.
+const SessionCookieEnv = "CZDOMAIN_SESSION_COOKIE"
+
+// sessionCookie is sent as the Cookie header of every request, typically the
+// session of a browser that solved the captcha.
+var sessionCookie string
+
+// registrableOnly reduces subdomains to the registrable domain instead of
+// rejecting them, see -registrable (or its alias -allow-subdomains).
+var registrableOnly bool
+
+// HostPrefixes are the subdomains stripped from pasted URLs, so that
+// www.example.cz checks example.cz.
+var HostPrefixes = []string{"www.", "m."}
+
+// DefaultTLD is appended to domains given without one.
+const DefaultTLD = "cz"
+
+var retries = Retries
+
+var (
+	baseURL   = BaseURL
+	whoisPath = WhoisPath
+	tld       = DefaultTLD
+)
+
+// CaptchaRetries is how many times a captcha page is re-fetched before the
+// user is asked to solve it.
+const CaptchaRetries = 2
+
+// CaptchaRetryDelay is the minimum wait before re-fetching a captcha page. A
+// random jitter up to the same length is added to it.
+const CaptchaRetryDelay = 5 * time.Second
+
+// ErrLayoutChanged means the page matched none of the haystacks, most likely
+// because nic.cz changed its layout.
+var ErrLayoutChanged = errors.New("Unexpected page layout")
+
+// ErrCaptchaRequired means the registry insists on a captcha and there's no
+// terminal to ask the user to solve it.
+var ErrCaptchaRequired = errors.New("Captcha required")
+
+// PlausiblePastDays is how long ago a domain shown by the registry may have
+// expired. Expired .cz domains are deleted about two months after expiration.
+const PlausiblePastDays = 90
+
+// PlausibleFutureDays is how far ahead an expiration may be. Domains are
+// registered for at most ten years.
+const PlausibleFutureDays = 11 * 365
+
+// ErrImplausibleExpiration means the parsed expiration date is out of the
+// plausible window, most likely because the page layout changed and the
+// offset points at a different field.
+var ErrImplausibleExpiration = errors.New("Implausible expiration date")
+
+// ErrInvalidDomain means the input can't be normalized to a checkable domain.
+var ErrInvalidDomain = errors.New("Invalid domain")
+
+// ErrUnreachable means the registry couldn't be queried or refused the query.
+var ErrUnreachable = errors.New("Registry unreachable")
+
+// ErrTimedOut means a domain took longer than -timeout-per-domain, or the
+// run reached -max-runtime.
+var ErrTimedOut = errors.New("Timed out")
+
+// ErrTruncatedResponse means a successful response came with an empty or
+// suspiciously short body, typically a connection reset after the headers.
+var ErrTruncatedResponse = errors.New("Truncated response")
+
+// ErrMissingExpiration means the response of a registered domain didn't have
+// an expiration date the method could read. Every method fails such a domain
+// rather than passing it as fine, so -strict-expiration is always in effect.
+var ErrMissingExpiration = errors.New("No expiration date")
+
+// ErrServiceUnavailable means the registry answered with its maintenance or
+// outage page instead of the result. It's retried like an unreachable
+// registry.
+var ErrServiceUnavailable = errors.New("Registry temporarily unavailable")
+
+// ErrRateLimited means the registry refused the query for coming too soon
+// after the previous ones, with its throttle page or a 429. It slows the
+// requests down and is retried like an unreachable registry.
+var ErrRateLimited = errors.New("Rate limited by the registry")
+
+// MinBodySize is the length under which a page is considered truncated; even
+// the shortest registry answer is longer.
+const MinBodySize = 32
+
+// Retries is the default of -retries, the number of times a query that failed
+// on the way (unreachable registry, truncated response) is repeated.
+const Retries = 2
+
+// RetryDelay is the wait before the first repeated query, doubled for each
+// next one.
+const RetryDelay = 2 * time.Second
+
+// Exit codes of the process. When several domains fail, the highest wins.
+const (
+	ExitOK            = 0
+	ExitCheckFailed   = 1
+	ExitInvalidDomain = 2
+	ExitMismatch      = 3
+	ExitChanged       = 4
+)
+
+// Exit codes of -only-changed-exit, which say whether -compare-with found
+// changes. A failed or mismatched check wins over the changes.
+const (
+	ExitNoChanges    = 0
+	ExitChangesFound = 1
+	ExitRunError     = 2
+)
+
+// Exit codes of -single-exit-codes, which encode the availability of the one
+// domain checked rather than how the run went.
+const (
+	ExitSingleFree   = 0
+	ExitSingleTaken  = 10
+	ExitSingleFailed = 11
+)
+
+var (
+	exitMu   sync.Mutex
+	exitCode = ExitOK
+)
+
+// setExitCode raises the exit code of the process to code.
+func setExitCode(code int) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+
+	if code > exitCode {
+		exitCode = code
+	}
+}
+
+// exitCodeFor maps the error of a check to the exit code it deserves.
+func exitCodeFor(err error) int {
+	if errors.Is(err, ErrInvalidDomain) {
+		return ExitInvalidDomain
+	}
+
+	return ExitCheckFailed
+}
+
+// warnDays is the default threshold of the expiration warnings, see
+// -warn-days.
+var warnDays int
+
+// expectedNameservers are the nameservers every domain must have, see
+// -check-ns-match.
+var expectedNameservers []string
+
+var (
+	captchaRetries      = CaptchaRetries
+	plausiblePastDays   = PlausiblePastDays
+	plausibleFutureDays = PlausibleFutureDays
+	expirationIndex     = 0
+)
+
 // CheckResult holds the result of a domain check.
 type CheckResult struct {
-	URL        string
-	IsFree     bool
-	Expiration time.Time
+	URL         string
+	IsFree      bool
+	Expiration  time.Time
+	Registered  time.Time
+	Nameservers []string
+	Keyset      string
+	Registrar   string
+	Statuses    []string
+	Method      string
+
+	// ContactEmail is the e-mail of the technical contact, or of the admin
+	// one, where to send e.g. an abuse report. Only the whois43 method reads
+	// it and only if the contact discloses it.
+	ContactEmail string
+
+	// DropDate is when an expired domain is deleted and becomes free, zero
+	// for the other ones. Only an RDAP deletion event gives it; otherwise
+	// it's estimated as the expiration plus the protection period of the
+	// registry rules, and DropDateEstimated is set.
+	DropDate          time.Time
+	DropDateEstimated bool
+
+	// RawExpiration is the expiration as the registry wrote it, before
+	// parsing.
+	RawExpiration string
+
+	// FinalURL is where the http method's query was redirected to, empty
+	// if it wasn't. A page found elsewhere was likely misparsed.
+	FinalURL string
+
+	// Status is the state of the domain. A reserved name isn't free even
+	// though nobody holds it. IsFree is kept in step with it.
+	Status Status
+
+	// RecentlyDropped marks a free domain this process saw registered
+	// earlier, see -refresh-interval. Neither the web page nor port 43 tell
+	// a dropped domain from a never registered one, so false means unknown
+	// rather than never registered.
+	RecentlyDropped bool
+
+	// Fields are all the key/value pairs of the response, for an attribute
+	// without a field of its own: the lines of every block, in order, for
+	// whois43, the JSON flattened to paths like events.1.eventAction for
+	// rdap, none for http. The keys are the registry's and may change, the
+	// fields above are the supported surface.
+	Fields []Field
+}
+
+// Field is a key and its value in the response of the registry.
+type Field struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// DomainError is the failure of a check of a single domain.
+type DomainError struct {
+	Domain string
+	Err    error
+}
+
+func (e *DomainError) Error() string {
+	return e.Domain + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *DomainError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError collects all the per-domain failures of a CheckDomains run.
+type BatchError struct {
+	Errors []error
+}
+
+func (e *BatchError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Unwrap returns the collected *DomainError values so that errors.Is and
+// errors.As look into each of them.
+func (e *BatchError) Unwrap() []error {
+	return e.Errors
+}
+
+// batchStats collects the timing data printed by the -stats mode.
+type batchStats struct {
+	mu      sync.Mutex
+	start   time.Time
+	count   int
+	latency time.Duration
+}
+
+func newBatchStats() *batchStats {
+	return &batchStats{start: time.Now()}
+}
+
+func (s *batchStats) record(latency time.Duration) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.count++
+	s.latency += latency
+}
+
+func (s *batchStats) report() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elapsed := time.Since(s.start)
+	average := time.Duration(0)
+	throughput := 0.0
+
+	if s.count > 0 {
+		average = s.latency / time.Duration(s.count)
+	}
+
+	if elapsed > 0 {
+		throughput = float64(s.count) / elapsed.Minutes()
+	}
+
+	log.Printf("Checked %s domains in %s (average latency %s, %s checks/min, %s requests to the registry)\n",
+		lang.number(s.count), elapsed.Round(time.Millisecond), average.Round(time.Millisecond), lang.decimalNumber(throughput),
+		lang.number(int(registryRequests.Load())))
 }
 
-func getPageContent(url string) (string, error) {
-	response, e := http.Get(url)
+// getPageContent returns the body of url and the URL it was read from after
+// any redirects.
+func getPageContent(ctx context.Context, url string) (string, string, error) {
+	request, e := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+
+	if e != nil {
+		return "", "", e
+	}
+
+	if sessionCookie != "" {
+		request.Header.Set("Cookie", sessionCookie)
+	}
+
+	// Asking explicitly turns off the transport's transparent gzip, so
+	// decodeBody takes care of both encodings.
+	request.Header.Set("Accept-Encoding", "gzip, deflate")
+
+	if traceRequests {
+		request = withTrace(request)
+	}
+
+	if e = limiter.wait(ctx); e != nil {
+		return "", "", e
+	}
+
+	response, e := clientFor(ctx).Do(request)
 
 	if e != nil {
-		return "", e
+		return "", "", fmt.Errorf("%w: %v", ErrUnreachable, e)
+	}
+
+	if response.StatusCode == http.StatusServiceUnavailable {
+		closeBody(response.Body)
+		return "", "", fmt.Errorf("%w: returned code %d", ErrServiceUnavailable, response.StatusCode)
+	}
+
+	if response.StatusCode == http.StatusTooManyRequests {
+		closeBody(response.Body)
+		return "", "", fmt.Errorf("%w: returned code %d", ErrRateLimited, response.StatusCode)
 	}
 
 	if response.StatusCode != 200 {
-		return "", errors.New("Returned code " + strconv.Itoa(response.StatusCode))
+		closeBody(response.Body)
+		return "", "", fmt.Errorf("%w: returned code %s", ErrUnreachable, strconv.Itoa(response.StatusCode))
+	}
+
+	defer closeBody(response.Body)
+
+	body, e := decodeBody(response)
+
+	if e != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrUnreachable, e)
+	}
+
+	content, e := readLimited(body)
+
+	if e == nil {
+		if content, e = toUTF8(content, pageCharset(response.Header.Get("Content-Type"), content)); e != nil {
+			e = fmt.Errorf("%w: %v", ErrUnreachable, e)
+		}
+	}
+
+	if e == nil && len(strings.TrimSpace(content)) < MinBodySize {
+		e = fmt.Errorf("%w: %d bytes", ErrTruncatedResponse, len(content))
 	}
 
-	defer response.Body.Close()
+	return content, response.Request.URL.String(), e
+}
+
+// decodeBody undoes the Content-Encoding of response. Deflate is meant to be
+// zlib wrapped, but some servers send a raw stream, so that's tried too.
+func decodeBody(response *http.Response) (io.Reader, error) {
+	switch strings.ToLower(response.Header.Get("Content-Encoding")) {
+	case "", "identity":
+		return response.Body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(response.Body)
+	case "deflate":
+		reader := bufio.NewReader(response.Body)
+		if header, err := reader.Peek(2); err == nil && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+			return zlib.NewReader(reader)
+		}
+		return flate.NewReader(reader), nil
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", response.Header.Get("Content-Encoding"))
+	}
+}
 
+// readLimited reads r whole, failing if it's longer than maxBodySize.
+func readLimited(r io.Reader) (string, error) {
 	buf := new(bytes.Buffer)
-	buf.ReadFrom(response.Body)
+
+	if _, e := buf.ReadFrom(io.LimitReader(r, maxBodySize+1)); e != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnreachable, e)
+	}
+
+	if int64(buf.Len()) > maxBodySize {
+		return "", fmt.Errorf("Response is larger than %d bytes", maxBodySize)
+	}
 
 	return buf.String(), nil
 }
 
-func waitForUser() {
-	reader := bufio.NewReader(os.Stdin)
-	reader.ReadString('\n')
+func queryURL(domain string) string {
+	path := whoisPath
+	if strings.Contains(path, "%s") {
+		path = strings.Replace(path, "%s", domain, -1)
+	} else {
+		path += domain
+	}
+
+	return strings.TrimSuffix(baseURL, "/") + path
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// stdin is shared by the prompts, so that what one read ahead isn't lost to
+// the next.
+var stdin = bufio.NewReader(os.Stdin)
+
+// stdinLine is a line read from stdin, or the error that ended it.
+type stdinLine struct {
+	text string
+	err  error
+}
+
+var (
+	stdinLines    = make(chan stdinLine)
+	stdinLinesRun sync.Once
+)
+
+// errIdle means nothing was entered within -max-idle.
+var errIdle = errors.New("No input")
+
+// readLine returns the next line of stdin, or io.EOF once it's closed. With
+// idle set it gives up with errIdle after that long; the reading goes on in
+// the background and the line is returned by the next call.
+func readLine(idle time.Duration) (string, error) {
+	stdinLinesRun.Do(func() {
+		go func() {
+			defer close(stdinLines)
+			for {
+				text, err := stdin.ReadString('\n')
+				if err != nil && text == "" {
+					stdinLines <- stdinLine{err: err}
+					return
+				}
+				stdinLines <- stdinLine{text: text}
+			}
+		}()
+	})
+
+	var timeout <-chan time.Time
+	if idle > 0 {
+		timer := time.NewTimer(idle)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case line, ok := <-stdinLines:
+		if !ok {
+			return "", io.EOF
+		}
+		return line.text, line.err
+	case <-timeout:
+		return "", errIdle
+	}
+}
+
+// waitForUser waits for the user to press enter. It returns false when stdin
+// is closed and there's nobody to wait for.
+func waitForUser() bool {
+	_, err := readLine(0)
+	return err == nil
 }
 
 func strToDate(date string) (time.Time, error) {
@@ -72,167 +612,1484 @@ func strToDate(date string) (time.Time, error) {
 	return time.Parse(time.RFC3339, str)
 }
 
-func reportDay(expiration int) string {
-	if expiration < 0 {
-		expiration = -expiration
-	}
+// String formats the result as a report line relative to the current time.
+func (r *CheckResult) String() string {
+	return r.format(time.Now(), lang)
+}
 
-	switch {
-	case expiration == 0:
-		return "today"
-	case expiration == 1:
-		return "1 day"
-	default:
-		return strconv.Itoa(expiration) + " days"
+func (r *CheckResult) format(now time.Time, l *language) string {
+	if reportZone != nil {
+		// The dates are the registry's calendar days at midnight UTC;
+		// counting from the start of today in the zone counts whole days.
+		year, month, day := now.In(reportZone).Date()
+		now = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
 	}
-}
 
-func report(result *CheckResult) {
 	res := ""
-	if result.IsFree {
-		res = "Free"
+	if r.Status == StatusReserved {
+		res = l.reserved
+	} else if r.IsFree && r.RecentlyDropped {
+		res = l.dropped
+	} else if r.IsFree {
+		res = l.free
 	} else {
-		exp := int((result.Expiration.Sub(time.Now())).Hours() / 24)
-		day := reportDay(exp)
+		exp := r.daysLeft(now)
 
 		switch {
 		case exp == 0:
-			res = fmt.Sprintf("Expires %s", day)
-			break
+			res = l.today
+		case exp < 0 && !r.DropDate.IsZero():
+			drop := int(r.DropDate.Sub(now).Hours() / 24)
+			if drop < 0 {
+				drop = 0
+			}
+			res = l.expired(-exp) + ", " + l.dropsIn(drop)
+			if r.DropDateEstimated {
+				res += l.estimated
+			}
 		case exp < 0:
-			res = fmt.Sprintf("Expired %s ago", day)
-			break
+			res = l.expired(-exp)
 		default:
-			res = fmt.Sprintf("Expires in %s", day)
+			res = l.expires(exp)
 		}
 	}
 
-	log.Printf("%s\t%s\n", result.URL, res)
+	if showDate && !r.Expiration.IsZero() && !r.IsFree && r.Status != StatusReserved {
+		res += " (" + r.Expiration.Format(reportDateFormat) + ")"
+	}
+
+	return fmt.Sprintf("%s\t%s", displayName(r.URL), res)
+}
+
+// IsExpiringSoon tells whether the domain is registered and expires within d,
+// or has already expired.
+func (r *CheckResult) IsExpiringSoon(d time.Duration) bool {
+	return r.expiresBefore(time.Now().Add(d))
+}
+
+func (r *CheckResult) expiresBefore(deadline time.Time) bool {
+	return !r.IsFree && r.Status != StatusReserved && !r.Expiration.IsZero() && r.Expiration.Before(deadline)
+}
+
+// daysLeft returns the whole days from now until the expiration, negative
+// once it passed.
+func (r *CheckResult) daysLeft(now time.Time) int {
+	return int(r.Expiration.Sub(now).Hours() / 24)
+}
+
+// explainResults makes the parsers describe how they derived each result.
+var explainResults bool
+
+func explain(url, format string, args ...interface{}) {
+	if explainResults {
+		log.Printf("explain %s: %s", url, fmt.Sprintf(format, args...))
+	}
 }
 
 func processURLResult(url, content string) (*CheckResult, error) {
 	ret := new(CheckResult)
 	ret.URL = url
-	ret.IsFree = strings.Contains(content, HaystackFree)
 
-	if ret.IsFree {
-		return ret, nil
-	}
+	// The details of a registered domain are authoritative: the free and
+	// reserved phrases may also appear in help or suggestion texts, so they
+	// only count on a page without an expiration date.
+	sub, at, err := findExpiration(content)
+
+	if err != nil {
+		if at := strings.Index(content, haystacks.Reserved); at >= 0 {
+			explain(url, "reserved: %q found at byte %d", haystacks.Reserved, at)
+			ret.Status = StatusReserved
+			return ret, nil
+		}
+
+		if at := strings.Index(content, haystacks.Free); at >= 0 {
+			explain(url, "free: %q found at byte %d", haystacks.Free, at)
+			ret.Status = StatusFree
+			return ret, nil
+		}
 
-	index := strings.Index(content, HaystackExpiration)
-	sub := content[index+ExpirationOffset : index+ExpirationOffset+ExpirationLength]
+		explain(url, "neither %q nor a date after %q found in %d bytes", haystacks.Free, haystacks.Expiration, len(content))
+		return nil, err
+	}
 
-	var err error
+	explain(url, "registered: %q found at byte %d, date %q read %d bytes after it", haystacks.Expiration, at, sub, haystacks.ExpirationOffset)
+	ret.RawExpiration = sub
 	ret.Expiration, err = strToDate(sub)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if err = checkPlausible(ret.Expiration, time.Now()); err != nil {
+		return nil, err
+	}
+
+	ret.Statuses = cellValues(content, haystacks.Status)
+	ret.Status = registrationStatus(ret.Expiration, time.Now(), ret.Statuses)
+
 	return ret, nil
 }
 
-func normalizeCzURL(urlAddr string) (string, error) {
-	urlAddr = strings.TrimSpace(urlAddr)
-
-	if !strings.HasPrefix(urlAddr, "http://") {
-		urlAddr = "http://" + urlAddr
+// cellValues returns the lines of the table cell following label, as broken
+// by its markup, with the markup stripped. It returns nil if there's no such
+// cell.
+func cellValues(content, label string) []string {
+	index := strings.Index(content, label)
+	if index < 0 {
+		return nil
 	}
 
-	if !strings.HasSuffix(urlAddr, ".cz") {
-		urlAddr = urlAddr + ".cz"
+	rest := content[index+len(label):]
+	start := strings.Index(rest, "<td")
+	if start < 0 {
+		return nil
 	}
 
-	parsed, e := url.Parse(urlAddr)
+	rest = rest[start:]
+	end := strings.Index(rest, "</td>")
+	if end < 0 {
+		return nil
+	}
 
-	if e != nil {
-		return "", e
+	open := strings.Index(rest, ">")
+	if open < 0 || open > end {
+		return nil
 	}
 
-	if strings.Count(parsed.Host, ".") > 1 {
-		return "", errors.New("You can check only second-level .cz domains")
+	// Line breaks of the source are spaces like in a browser, only the
+	// markup breaks the values apart.
+	cell := strings.Join(strings.Fields(rest[open+1:end]), " ")
+	cell = strings.NewReplacer("<br>", "\n", "<br/>", "\n", "<br />", "\n", "</li>", "\n", "</p>", "\n", "</div>", "\n").Replace(cell)
+
+	var values []string
+	for _, line := range strings.Split(stripTags(cell), "\n") {
+		if line = strings.TrimSpace(html.UnescapeString(line)); line != "" {
+			values = append(values, line)
+		}
 	}
 
-	return parsed.Host, nil
+	return values
 }
 
-// CheckURL checks if a domain (url) is free to register.
-func CheckURL(url string) (*CheckResult, error) {
-	content := ""
-	normalizedURL, err := normalizeCzURL(url)
+func stripTags(s string) string {
+	var b strings.Builder
+	inTag := false
 
-	if err != nil {
-		return nil, err
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>':
+			inTag = false
+		case !inTag:
+			b.WriteRune(r)
+		}
 	}
 
-	for {
-		query := BaseURL + normalizedURL
-		pageContent, err := getPageContent(query)
+	return b.String()
+}
 
-		if err != nil {
-			return nil, err
+// findExpiration returns the raw expiration date following an occurrence of
+// HaystackExpiration. The label may also appear outside the domain details
+// (in a header or a script), so unless -expiration-index picks one, the first
+// occurrence actually followed by a date wins.
+func findExpiration(content string) (string, int, error) {
+	offset := 0
+
+	for n := 1; ; n++ {
+		index := strings.Index(content[offset:], haystacks.Expiration)
+
+		if index < 0 {
+			return "", 0, fmt.Errorf("%w: %w found", ErrLayoutChanged, ErrMissingExpiration)
 		}
 
-		if strings.Contains(pageContent, HaystackCaptcha) {
-			fmt.Printf("Go to %s and check the captcha.\nPress enter to continue.", query)
-			waitForUser()
-		} else {
-			content = pageContent
-			break
+		at := offset + index
+		start := at + haystacks.ExpirationOffset
+		offset = at + len(haystacks.Expiration)
+
+		if start+ExpirationLength > len(content) {
+			continue
 		}
-	}
 
-	return processURLResult(normalizedURL, content)
+		sub := content[start : start+ExpirationLength]
+
+		if n == expirationIndex {
+			return sub, at, nil
+		}
+
+		if _, err := strToDate(sub); expirationIndex == 0 && err == nil {
+			return sub, at, nil
+		}
+	}
 }
 
-func processURL(url string) {
-	result, err := CheckURL(url)
+func checkPlausible(expiration, now time.Time) error {
+	days := expiration.Sub(now).Hours() / 24
 
-	if err != nil {
-		log.Fatalf("%s\t%s", url, err)
-	} else {
-		report(result)
-		time.Sleep(Politeness)
+	if days < -float64(plausiblePastDays) || days > float64(plausibleFutureDays) {
+		return fmt.Errorf("%w %s", ErrImplausibleExpiration, expiration.Format("2006-01-02"))
 	}
+
+	return nil
 }
 
-func getUserURL() string {
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("\nEnter domain: ")
-	domain, _ := reader.ReadString('\n')
-	return strings.Replace(domain, "\n", "", -1)
+func normalizeCzURL(urlAddr string) (string, error) {
+	return normalizeDomain(urlAddr, tld)
 }
 
-func startArgLoop(urls []string) {
-	for _, url := range urls {
-		processURL(url)
+// normalizeDomain returns the host of urlAddr, appending tld to a bare name.
+// Names that already have a TLD keep it, provided there's a checker for it.
+// Of a pasted URL only the host counts: the port, path and query are ignored
+// and a leading www. (or another of HostPrefixes) is dropped. An IP address
+// is rejected, and so is a name breaking the DNS rules, see checkHostName.
+//
+// A host with more labels than its registrable domain is an error unless
+// registrableOnly, which keeps the registrable domain: the labels under the
+// longest of the registry's suffixes, so a.b.example.cz checks example.cz.
+// The prefixes are dropped first, so www.example.cz is never a subdomain.
+// Labels are compared as given: an internationalized name has to be passed
+// in its xn-- form, which reduces like any other.
+func normalizeDomain(urlAddr, tld string) (string, error) {
+	urlAddr = strings.TrimSpace(urlAddr)
+
+	// A bare IPv6 address isn't a valid URL host, so it's caught before
+	// parsing makes a host of it.
+	if ip := net.ParseIP(strings.Trim(urlAddr, "[]")); ip != nil {
+		return "", fmt.Errorf("%w: expected a domain name, got the IP address %s", ErrInvalidDomain, ip)
 	}
-}
 
-func startInteractiveLoop() {
-	for {
-		processURL(getUserURL())
+	if !strings.Contains(urlAddr, "://") {
+		urlAddr = "http://" + urlAddr
 	}
-}
 
-func printUsage() {
-	fmt.Printf("Usage: %s domain1[.cz][ domain2[ domain3]...]\n", os.Args[0])
-	fmt.Println("Available arguments:")
-	flag.PrintDefaults()
-}
+	parsed, e := url.Parse(urlAddr)
 
-func main() {
-	interactive := flag.Bool("i", false, "Interactive mode")
-	flag.Parse()
+	if e != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidDomain, e)
+	}
 
-	if *interactive {
-		fmt.Println("Press CTRL-C to quit.")
-		startInteractiveLoop()
-	} else {
-		if len(flag.Args()) > 0 {
-			startArgLoop(flag.Args())
-		} else {
-			printUsage()
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+
+	if host == "" {
+		return "", fmt.Errorf("%w: no host name", ErrInvalidDomain)
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return "", fmt.Errorf("%w: expected a domain name, got the IP address %s", ErrInvalidDomain, ip)
+	}
+
+	for _, prefix := range HostPrefixes {
+		if strings.Count(host, ".") > 1 && strings.HasPrefix(host, prefix) {
+			host = strings.TrimPrefix(host, prefix)
+			break
 		}
 	}
+
+	if dot := strings.LastIndex(host, "."); dot < 0 {
+		host = host + "." + tld
+	} else {
+		tld = host[dot+1:]
+	}
+
+	if err := checkHostName(host); err != nil {
+		return "", err
+	}
+
+	checker, ok := supportedTLDs[tld]
+	if !ok {
+		return "", fmt.Errorf("%w: no checker is available for .%s domains", ErrInvalidDomain, tld)
+	}
+
+	if registrable := checker.registrable(host); registrableOnly {
+		host = registrable
+	} else if registrable != host {
+		return "", fmt.Errorf("%w: you can check only second-level .%s domains", ErrInvalidDomain, tld)
+	}
+
+	return host, nil
+}
+
+// MaxLabelLength and MaxHostLength are the DNS limits on a label and on a
+// whole name, RFC 1035 section 2.3.4.
+const (
+	MaxLabelLength = 63
+	MaxHostLength  = 253
+)
+
+// checkHostName rejects a host no registry could have: too long, with an
+// empty or too long label, or with a label that isn't letters, digits and
+// inner hyphens.
+func checkHostName(host string) error {
+	if len(host) > MaxHostLength {
+		return fmt.Errorf("%w: the name is %d characters long, the limit is %d", ErrInvalidDomain, len(host), MaxHostLength)
+	}
+
+	for _, label := range strings.Split(host, ".") {
+		switch {
+		case label == "":
+			return fmt.Errorf("%w: empty label in %s", ErrInvalidDomain, host)
+		case len(label) > MaxLabelLength:
+			return fmt.Errorf("%w: the label %s is %d characters long, the limit is %d", ErrInvalidDomain, label, len(label), MaxLabelLength)
+		case label[0] == '-' || label[len(label)-1] == '-':
+			return fmt.Errorf("%w: the label %s starts or ends with a hyphen", ErrInvalidDomain, label)
+		}
+
+		for _, r := range label {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+				return fmt.Errorf("%w: %q isn't allowed in the label %s, only letters, digits and hyphens are (an internationalized name goes in its xn-- form)", ErrInvalidDomain, r, label)
+			}
+		}
+	}
+
+	return nil
+}
+
+// expandTLDs replaces every bare label among urls with the label under each
+// of tlds, keeping them next to each other.
+func expandTLDs(urls, tlds []string) []string {
+	var expanded []string
+
+	for _, url := range urls {
+		if strings.Contains(url, ".") {
+			expanded = append(expanded, url)
+			continue
+		}
+
+		for _, tld := range tlds {
+			domain := strings.TrimSpace(url) + "." + strings.TrimPrefix(strings.TrimSpace(tld), ".")
+			if options, ok := perDomain[url]; ok {
+				perDomain[domain] = options
+			}
+			if files, ok := sources[url]; ok {
+				sources[domain] = files
+			}
+			expanded = append(expanded, domain)
+		}
+	}
+
+	return expanded
+}
+
+// methods maps the -method names to the functions looking up a normalized
+// domain.
+var methods = map[string]func(ctx context.Context, domain string) (*CheckResult, error){
+	"http":    checkHTTP,
+	"whois43": checkWhois43,
+	"rdap":    checkRDAP,
+}
+
+var method = "http"
+
+// fallback makes the http method retry the whois43 one when the page can't
+// be parsed.
+var fallback bool
+
+// CheckURL checks if a domain (url) is free to register.
+func CheckURL(url string) (*CheckResult, error) {
+	return CheckURLContext(context.Background(), url)
+}
+
+// CheckURLContext is CheckURL giving up once ctx is done. Running out of
+// time is reported as ErrTimedOut.
+func CheckURLContext(ctx context.Context, url string) (*CheckResult, error) {
+	result, err := checkURL(ctx, url)
+
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && !errors.Is(err, ErrTimedOut) {
+		return nil, fmt.Errorf("%w: %v", ErrTimedOut, err)
+	}
+
+	return result, err
+}
+
+func checkURL(ctx context.Context, url string) (*CheckResult, error) {
+	normalizedURL, err := normalizeCzURL(url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	check, ok := methods[method]
+
+	if !ok {
+		return nil, errors.New("Unknown method " + method)
+	}
+
+	used := method
+	result, err := check(ctx, normalizedURL)
+	noteLayoutError(err)
+
+	if fallback && method == "http" && errors.Is(err, ErrLayoutChanged) {
+		used = "whois43"
+		result, err = checkWhois43(ctx, normalizedURL)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return completeResult(result, used), nil
+}
+
+// completeResult fills in what follows from a parsed result: the method it
+// came from, IsFree and, unless the registry gave it, an estimated DropDate.
+func completeResult(result *CheckResult, method string) *CheckResult {
+	result.Method = method
+	result.IsFree = result.Status == StatusFree
+
+	expired := result.Status == StatusExpired || result.Status == StatusProtected
+	if !expired {
+		result.DropDate = time.Time{}
+	} else if result.DropDate.IsZero() {
+		result.DropDate = result.Expiration.AddDate(0, 0, protectionDays)
+		result.DropDateEstimated = true
+	}
+
+	return result
+}
+
+// ParseWhois parses WHOIS content of domain fetched beforehand, such as a
+// saved page or port 43 reply. The format is detected: an HTML page is read
+// like the http method reads the nic.cz page, anything else as a port 43
+// reply. A captcha page gives ErrCaptchaRequired, a maintenance page
+// ErrServiceUnavailable and a throttle page ErrRateLimited.
+func ParseWhois(domain, content string) (*CheckResult, error) {
+	normalizedURL, err := normalizeCzURL(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isHTML(content) {
+		result, err := processWhoisResult(normalizedURL, content)
+		if err != nil {
+			return nil, err
+		}
+		return completeResult(result, "whois43"), nil
+	}
+
+	if err := checkUnavailable(content); err != nil {
+		return nil, err
+	}
+	if marker, at := findCaptcha(content); at >= 0 {
+		return nil, fmt.Errorf("%w: %q found", ErrCaptchaRequired, marker)
+	}
+
+	result, err := processURLResult(normalizedURL, content)
+	if err != nil {
+		return nil, err
+	}
+	return completeResult(result, "http"), nil
+}
+
+// isHTML tells a web page from a port 43 reply, which is plain text.
+func isHTML(content string) bool {
+	start := strings.ToLower(strings.TrimSpace(content))
+	return strings.HasPrefix(start, "<") || strings.Contains(start, "<html")
+}
+
+// fixturesDir replaces the registry with saved responses, see readFixture.
+var fixturesDir string
+
+// readFixture returns the saved response for domain: <dir>/<domain>.html for
+// the http method, <dir>/<domain>.txt for whois43.
+func readFixture(domain, extension string) (string, error) {
+	path := filepath.Join(fixturesDir, domain+extension)
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		return "", fmt.Errorf("%w: no fixture %s", ErrUnreachable, path)
+	}
+
+	return string(content), nil
+}
+
+// fetchPage returns the page of query and the URL it came from. Transient
+// failures are retried as long as there's some of the domain's retriesLeft.
+func fetchPage(ctx context.Context, domain, query string, retriesLeft *int) (string, string, error) {
+	if fixturesDir != "" {
+		content, err := readFixture(domain, ".html")
+		if err == nil {
+			content, err = toUTF8(content, pageCharset("", content))
+		}
+		if err == nil {
+			err = checkUnavailable(content)
+		}
+		return content, query, err
+	}
+
+	for delay := RetryDelay; ; delay *= 2 {
+		content, finalURL, err := getPageContent(ctx, query)
+		if err == nil {
+			err = checkUnavailable(content)
+		}
+
+		if errors.Is(err, ErrRateLimited) {
+			// Slow all the workers down now instead of when the check gives up.
+			limiter.failed()
+			tuner.throttled()
+		}
+
+		if err == nil || *retriesLeft <= 0 || ctx.Err() != nil || !isTransient(err) || !runRetries.take() {
+			return content, finalURL, err
+		}
+
+		*retriesLeft--
+		log.Printf("%s\t%s, retrying in %s", domain, err, delay)
+		if e := sleep(ctx, delay); e != nil {
+			return "", "", err
+		}
+	}
+}
+
+// isTransient tells the failures of a query worth repeating a bit later.
+func isTransient(err error) bool {
+	return errors.Is(err, ErrUnreachable) || errors.Is(err, ErrTruncatedResponse) ||
+		errors.Is(err, ErrServiceUnavailable) || errors.Is(err, ErrRateLimited)
+}
+
+// checkUnavailable returns ErrRateLimited if content is the throttle page and
+// ErrServiceUnavailable if it's a maintenance page: one of ThrottleMarkers or
+// MaintenanceMarkers without any domain details, which would otherwise fail
+// as an unexpected layout or, for the throttle page, be taken for a captcha.
+func checkUnavailable(content string) error {
+	if strings.Contains(content, haystacks.Expiration) {
+		return nil
+	}
+
+	for _, marker := range ThrottleMarkers {
+		if strings.Contains(content, marker) {
+			return fmt.Errorf("%w: %q found", ErrRateLimited, marker)
+		}
+	}
+
+	for _, marker := range MaintenanceMarkers {
+		if strings.Contains(content, marker) {
+			return fmt.Errorf("%w: %q found", ErrServiceUnavailable, marker)
+		}
+	}
+
+	return nil
+}
+
+// findCaptcha returns the first of captchaMarkers found in content and its
+// position, or -1 if the page isn't a challenge.
+func findCaptcha(content string) (string, int) {
+	for _, marker := range captchaMarkers {
+		if at := strings.Index(content, marker); marker != "" && at >= 0 {
+			return marker, at
+		}
+	}
+
+	return "", -1
+}
+
+// ParseRetryDelay is the wait before -retry-on-parse-error fetches a page
+// again.
+const ParseRetryDelay = 3 * time.Second
+
+// retryOnParseError makes the http method fetch a page it couldn't parse once
+// more, out of the -retries of the domain.
+var retryOnParseError bool
+
+// isParseError tells a page that couldn't be parsed from a failed request.
+func isParseError(err error) bool {
+	var parseErr *time.ParseError
+	return errors.Is(err, ErrLayoutChanged) || errors.Is(err, ErrImplausibleExpiration) || errors.As(err, &parseErr)
+}
+
+func checkHTTP(ctx context.Context, normalizedURL string) (*CheckResult, error) {
+	retriesLeft := retries
+	refetched := false
+
+	for {
+		content, finalURL, err := fetchUnchallenged(ctx, normalizedURL, &retriesLeft)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := processURLResult(normalizedURL, content)
+
+		if err != nil && isParseError(err) && retryOnParseError && !refetched && retriesLeft > 0 && fixturesDir == "" && runRetries.take() {
+			refetched = true
+			retriesLeft--
+			log.Printf("%s\t%s, fetching the page again in %s", normalizedURL, err, ParseRetryDelay)
+			if e := sleep(ctx, ParseRetryDelay); e != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err != nil && finalURL != "" {
+			return nil, fmt.Errorf("%w (redirected to %s)", err, finalURL)
+		}
+
+		if result != nil {
+			result.FinalURL = finalURL
+		}
+
+		return result, err
+	}
+}
+
+// fetchUnchallenged fetches the page of a domain, retrying or asking the user
+// to solve a captcha until it gets one without it.
+func fetchUnchallenged(ctx context.Context, normalizedURL string, retriesLeft *int) (string, string, error) {
+	finalURL := ""
+
+	for attempt := 1; ; attempt++ {
+		query := queryURL(normalizedURL)
+		pageContent, pageURL, err := fetchPage(ctx, normalizedURL, query, retriesLeft)
+
+		if err != nil {
+			return "", "", err
+		}
+
+		if pageURL != query {
+			finalURL = pageURL
+			if traceRequests {
+				log.Printf("trace %s: redirected to %s", query, pageURL)
+			}
+		}
+
+		marker, at := findCaptcha(pageContent)
+		if at < 0 {
+			return pageContent, finalURL, nil
+		}
+
+		explain(normalizedURL, "captcha: %q found at byte %d", marker, at)
+		tuner.throttled()
+		// Unless -persist-cookies is set requests carry no cookies, so a
+		// retry after a short random delay starts a fresh session which
+		// often isn't challenged.
+		if attempt <= captchaRetries {
+			if err := sleep(ctx, CaptchaRetryDelay+time.Duration(rand.Int63n(int64(CaptchaRetryDelay)))); err != nil {
+				return "", "", err
+			}
+			continue
+		}
+
+		if err := captchas.solve(query); err != nil {
+			return "", "", err
+		}
+	}
+}
+
+// CheckDomains checks the domains one after another, within the shared rate
+// limit. It returns the successful results in input order and,
+// if any check failed, a *BatchError wrapping a *DomainError for each failure.
+func CheckDomains(urls []string) ([]*CheckResult, error) {
+	var results []*CheckResult
+	var errs []error
+
+	for _, url := range urls {
+		result, err := CheckURL(url)
+
+		if err != nil {
+			errs = append(errs, &DomainError{Domain: url, Err: err})
+		} else {
+			results = append(results, result)
+		}
+	}
+
+	if len(errs) > 0 {
+		return results, &BatchError{Errors: errs}
+	}
+
+	return results, nil
+}
+
+func processURL(ctx context.Context, url string, stats *batchStats) {
+	reportOutcome(checkOutcome(ctx, url, stats))
+}
+
+// checkOutcome checks url within timeoutPerDomain, and within ctx which bounds
+// the whole run.
+func checkOutcome(ctx context.Context, url string, stats *batchStats) outcome {
+	domainCtx := ctx
+	if timeoutPerDomain > 0 {
+		var cancel context.CancelFunc
+		domainCtx, cancel = context.WithTimeout(ctx, timeoutPerDomain)
+		defer cancel()
+	}
+
+	start := time.Now()
+	result, err := CheckURLContext(domainCtx, url)
+	elapsed := time.Since(start)
+	stats.record(elapsed)
+
+	// A throttled query has already slowed the workers down when it was seen.
+	switch {
+	case err == nil:
+		limiter.succeeded()
+	case errors.Is(err, ErrRateLimited):
+	case isTransient(err), errors.Is(err, ErrTimedOut):
+		limiter.failed()
+	}
+
+	if errors.Is(err, ErrTimedOut) && ctx.Err() != nil {
+		err = fmt.Errorf("%w: the -max-runtime of %s ran out", ErrTimedOut, maxRuntime)
+	} else if errors.Is(err, ErrTimedOut) {
+		err = fmt.Errorf("%w: no result within %s", ErrTimedOut, timeoutPerDomain)
+	}
+
+	o := outcome{url: url, result: result, err: err, elapsed: elapsed}
+	collected.add(o)
+	history.checkRenewal(o, time.Now())
+	history.record(o)
+	return o
+}
+
+// reportOutcome prints the result line and the warnings of one check.
+func reportOutcome(o outcome) {
+	url, result, err := o.url, o.result, o.err
+	resultPipe.write(o)
+
+	if err != nil {
+		log.Printf("%s\t%s", url, err)
+		setExitCode(exitCodeFor(err))
+
+		if reportResults {
+			for _, source := range sourceTags(url) {
+				reportError(os.Stdout, url, source, err)
+			}
+		}
+	} else {
+		if reportResults {
+			for _, source := range sourceTags(url) {
+				reportFrom(os.Stdout, result, source)
+			}
+		}
+
+		if days := optionsFor(url).warnDays; days > 0 && result.IsExpiringSoon(time.Duration(days)*24*time.Hour) {
+			log.Printf("%s\tWarning: expires within %d days", result.URL, days)
+		}
+
+		if nameserverMismatch(result) {
+			log.Printf("%s\tNameservers [%s] don't match the expected [%s]", result.URL,
+				strings.Join(result.Nameservers, ", "), strings.Join(expectedNameservers, ", "))
+			setExitCode(ExitMismatch)
+		}
+	}
+}
+
+// timeoutPerDomain bounds the check of each domain of the argument loop, and
+// maxRuntime all of them together. Zero means no limit.
+var (
+	timeoutPerDomain time.Duration
+	maxRuntime       time.Duration
+)
+
+// batchSize and batchPause split the argument loop into batches: after
+// batchSize domains it waits for them to finish, then sleeps batchPause.
+var (
+	batchSize  int
+	batchPause time.Duration
+)
+
+type indexedOutcome struct {
+	index   int
+	outcome outcome
+}
+
+// maxIdle ends the interactive mode when nothing was entered for that long,
+// see -max-idle. Zero waits forever.
+var maxIdle time.Duration
+
+// getUserURL prompts for a domain. It fails with io.EOF once stdin is closed
+// and with errIdle when nothing was entered within maxIdle.
+func getUserURL() (string, error) {
+	fmt.Fprint(os.Stderr, "\nEnter domain: ")
+	domain, err := readLine(maxIdle)
+	if err != nil {
+		return "", err
+	}
+	return strings.Replace(domain, "\n", "", -1), nil
+}
+
+// startArgLoop checks urls with concurrency workers. Results are reported as
+// they finish, or in the order of urls if ordered is set; a finished result
+// then waits only for those before it. With batchSize set, every batch is
+// finished before the batchPause and the next one.
+func startArgLoop(ctx context.Context, urls []string, showStats bool, concurrency int, ordered bool) {
+	var stats *batchStats
+	if showStats {
+		stats = newBatchStats()
+	}
+
+	queue := make(chan int)
+	done := make(chan indexedOutcome)
+	var workers, inFlight sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for index := range queue {
+				start := time.Now()
+				o := checkOutcome(ctx, urls[index], stats)
+				tuner.release(time.Since(start), o.err)
+
+				if ordered {
+					done <- indexedOutcome{index, o}
+				} else {
+					reportOrHold(o)
+				}
+				inFlight.Done()
+			}
+		}()
+	}
+
+	flushed := make(chan struct{})
+	go func() {
+		pending := map[int]outcome{}
+		next := 0
+		for finished := range done {
+			pending[finished.index] = finished.outcome
+			for o, ok := pending[next]; ok; o, ok = pending[next] {
+				reportOrHold(o)
+				delete(pending, next)
+				next++
+			}
+		}
+		close(flushed)
+	}()
+
+	for index := range urls {
+		if batchSize > 0 && index > 0 && index%batchSize == 0 {
+			inFlight.Wait()
+			log.Printf("Checked %s of %s domains, pausing for %s", lang.number(index), lang.number(len(urls)), batchPause)
+			sleep(ctx, batchPause)
+		}
+
+		captchas.wait()
+		tuner.acquire()
+		inFlight.Add(1)
+		queue <- index
+	}
+	close(queue)
+	workers.Wait()
+	close(done)
+	<-flushed
+	recheckHeld(ctx, stats)
+
+	if stats != nil {
+		stats.report()
+	}
+}
+
+// interactiveHelp lists the commands of the interactive mode.
+const interactiveHelp = `Enter a domain to check it, or one of the commands:
+  :help      show this help
+  :last      check the previous domain again
+  :settings  show the current flag values
+  :quit      quit (as does Ctrl-D)`
+
+func startInteractiveLoop() {
+	last := ""
+
+	for {
+		url, err := getUserURL()
+		if errors.Is(err, errIdle) {
+			fmt.Fprintf(os.Stderr, "\nNothing entered for %s, bye.\n", maxIdle)
+			return
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr)
+			return
+		}
+
+		url = strings.TrimSpace(url)
+		if !strings.HasPrefix(url, ":") {
+			processURL(context.Background(), url, nil)
+			last = url
+			continue
+		}
+
+		switch command := strings.ToLower(url); command {
+		case ":help", ":h", ":?":
+			fmt.Fprintln(os.Stderr, interactiveHelp)
+		case ":quit", ":q", ":exit":
+			return
+		case ":last", ":l":
+			if last == "" {
+				fmt.Fprintln(os.Stderr, "No domain checked yet")
+				continue
+			}
+			processURL(context.Background(), last, nil)
+		case ":settings":
+			flag.VisitAll(func(f *flag.Flag) {
+				value := f.Value.String()
+				if f.Name == "session-cookie" && value != "" {
+					value = "(hidden)"
+				}
+				fmt.Fprintf(os.Stderr, "  -%s=%s\n", f.Name, value)
+			})
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown command %s, :help lists them\n", command)
+		}
+	}
+}
+
+// hiddenFlags are left out of the usage, e.g. -completion, which is meant for
+// the shell setup rather than for daily use.
+var hiddenFlags = map[string]bool{"completion": true}
+
+func printUsage() {
+	writeUsage(os.Stdout)
+}
+
+// writeUsage writes the usage with the flags other than hiddenFlags.
+func writeUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [-f file] domain1[.cz][ domain2[ domain3]...]\n", os.Args[0])
+	fmt.Fprintln(w, "Available arguments:")
+
+	shown := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	shown.SetOutput(w)
+	flag.VisitAll(func(f *flag.Flag) {
+		if !hiddenFlags[f.Name] {
+			shown.Var(f.Value, f.Name, f.Usage)
+			shown.Lookup(f.Name).DefValue = f.DefValue
+		}
+	})
+	shown.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = func() { writeUsage(flag.CommandLine.Output()) }
+	interactive := flag.Bool("i", false, "Interactive mode")
+	flag.DurationVar(&maxIdle, "max-idle", 0, "Quit the interactive mode after `duration` with no input")
+	showStats := flag.Bool("stats", false, "Print elapsed time, average latency and throughput after a batch")
+	flag.StringVar(&baseURL, "base-url", BaseURL, "Registry `URL` to send queries to")
+	flag.StringVar(&whoisPath, "whois-path", WhoisPath, "WHOIS page `path`, the domain is appended or replaces %s")
+	flag.BoolVar(&registrableOnly, "registrable", false, "Check the registrable domain of a subdomain (a.b.example.cz checks example.cz) instead of rejecting it")
+	flag.BoolVar(&registrableOnly, "allow-subdomains", false, "Same as -registrable")
+	flag.BoolVar(&unicodeNames, "unicode", false, "Show internationalized domains (xn--...) in their Unicode form in the text report")
+	flag.BoolVar(&showDate, "show-date", false, "Append the expiration date to the text report lines")
+	flag.StringVar(&reportDateFormat, "date-format", DateFormat, "Go `layout` of the -show-date dates, e.g. 02.01.2006")
+	tz := flag.String("tz", "", "Count the days left from today in this `zone` (e.g. Europe/Prague, UTC, Local) rather than to the hour")
+	flag.StringVar(&tld, "tld", DefaultTLD, "`TLD` to append to domains given without one")
+	var inputFiles fileList
+	flag.Var(&inputFiles, "f", "Read the domains to check from `file` (- for stdin), repeat for several files")
+	flag.BoolVar(&mergeSources, "merge-sources", true, "Report a domain listed in several -f files once, rather than once per file")
+	inputFormat := flag.String("input-format", "text", "Format of the -f file: text (one domain per line) or json (array)")
+	tlds := flag.String("tlds", "", "Check bare names under each TLD of this comma-separated `list`")
+	envName := flag.String("env", ProductionEnv, "Registry `environment`: production, or one defined in the -env-file (e.g. a staging one)")
+	envFile := flag.String("env-file", environmentsPath(), "JSON `file` defining the -env environments")
+	flag.StringVar(&method, "method", "http", "Lookup `method`: http (scrape the web page), whois43 (port 43 WHOIS) or rdap (the JSON of the RDAP service)")
+	flag.DurationVar(&connectTimeout, "connect-timeout", ConnectTimeout, "Give up connecting to the registry after `duration`")
+	flag.DurationVar(&requestTimeout, "timeout", Timeout, "Give up a whole request after `duration`")
+	flag.BoolVar(&retryOnParseError, "retry-on-parse-error", false, "Fetch a page that couldn't be parsed once more, counting toward -retries")
+	flag.IntVar(&retries, "retries", Retries, "Repeat a query that failed on the way (unreachable, truncated) up to `n` times")
+	flag.BoolVar(&recheckFailed, "recheck-failed", false, "Check the domains that failed once more at the end of a batch, and report the final outcomes")
+	flag.IntVar(&runRetries.limit, "retry-budget", 0, "Retry at most `n` times in the whole run, whatever -retries allows each domain (0 is no limit)")
+	flag.Int64Var(&maxBodySize, "max-body", MaxBodySize, "Fail responses larger than `bytes`")
+	flag.BoolVar(&traceRequests, "trace", false, "Log DNS, connect, TLS and first byte timing of each request to stderr")
+	listCheckersMode := flag.Bool("list-checkers", false, "List the supported TLDs and their lookup methods, then exit")
+	historyFile := flag.String("history", "", "Append every check with its time to this jsonl `file`")
+	historyMaxSize := flag.Int64("history-max-size", HistoryMaxSize, "Rotate the -history file once it reaches `bytes`")
+	flag.Bool("strict-expiration", true, "Fail every registered domain whose expiration date can't be read; always on, every method does, accepted for the scripts passing it")
+	historyKeep := flag.Int("history-keep", HistoryKeep, "Keep `n` rotated -history files (file.1 being the newest)")
+	since := flag.String("since", "", "Report the status changes in the -history since this `time` (7d, 36h, 2026-10-01, an RFC 3339 time), then exit")
+	flag.IntVar(&renewalDays, "renewal-days", RenewalDays, "Warn about domains in the -history this close to their expiration `days` that weren't renewed (0 disables)")
+	flag.BoolVar(&includeRawDate, "include-raw-date", false, "Add the expiration as the registry wrote it to the jsonl output")
+	summaryJSON := flag.Bool("summary-json", false, "Write a JSON summary of the run (counts, duration, errors, exit code) to stderr at the end")
+	flag.BoolVar(&explainResults, "explain", false, "Describe to stderr which haystacks matched and where the date was read")
+	flag.StringVar(&fixturesDir, "fixtures", "", "Read the responses from `dir`/<domain>.html (or .txt for whois43, .json for rdap) instead of the network")
+	flag.BoolVar(&fallback, "fallback", false, "Retry with the whois43 method when the web page can't be parsed, and report the method used")
+	flag.StringVar(&whoisServer, "whois-server", WhoisServer, "WHOIS `host:port` used by the whois43 method")
+	flag.StringVar(&rdapServer, "rdap-url", RDAPServer, "RDAP service `URL` used by the rdap method")
+	concurrency := flag.Int("concurrency", 1, "Check up to `n` domains at once, still within the -rate limit")
+	flag.DurationVar(&timeoutPerDomain, "timeout-per-domain", 0, "Fail a domain whose check (including retries) takes longer than `duration`")
+	flag.DurationVar(&maxRuntime, "max-runtime", 0, "Fail the domains not checked within `duration` of the start of a batch")
+	profile := flag.String("profile", "", "Pace the queries by a `preset`: gentle, normal or fast (-rate, -concurrency, -delay-on-error and -max-error-delay given as flags win)")
+	delayOnError := flag.Duration("delay-on-error", DelayOnError, "Add `duration` to the delay between requests after each failed domain, and take it off after each success")
+	maxErrorDelay := flag.Duration("max-error-delay", MaxErrorDelay, "Add at most `duration` to the delay between requests after failures")
+	flag.IntVar(&batchSize, "batch-size", 0, "Check the domains in batches of `n`, with -batch-pause between them")
+	flag.DurationVar(&batchPause, "batch-pause", time.Minute, "Pause between the batches of -batch-size")
+	autoTune := flag.Bool("auto-tune", false, "Start checking one domain at a time and adapt up to -concurrency by how the registry copes")
+	ordered := flag.Bool("ordered", false, "With -concurrency, print the results in the input order instead of as they finish")
+	flag.StringVar(&captchaPrompt, "captcha-message", "", "Captcha prompt `template`, {url} is the page to solve it at (default depends on -lang)")
+	flag.StringVar(&captchaWebhook, "captcha-webhook", "", "POST a JSON notification to `URL` when a check hits a captcha")
+	flag.IntVar(&captchas.limit, "max-parallel-captchas", MaxParallelCaptchas, "Hold back new checks while more than `n` are blocked on a captcha")
+	refreshInterval := flag.Duration("refresh-interval", 0, "Keep re-checking the domains, each once per `interval`, and report changes")
+	flag.IntVar(&protectionDays, "protection-days", ProtectionDays, "Expired domains become free `days` after the expiration")
+	flag.IntVar(&plausiblePastDays, "plausible-past-days", PlausiblePastDays, "Reject expirations more than `days` in the past as misparsed")
+	flag.IntVar(&plausibleFutureDays, "plausible-future-days", PlausibleFutureDays, "Reject expirations more than `days` in the future as misparsed")
+	flag.IntVar(&expirationIndex, "expiration-index", 0, "Read the date after the `n`th expiration label (0 picks the first followed by a date)")
+	flag.IntVar(&captchaRetries, "captcha-retries", CaptchaRetries, "Re-fetch a captcha page `n` times after a random delay before asking to solve it")
+	markers := flag.String("captcha-markers", strings.Join(CaptchaMarkers, ","), "Comma-separated `list` of strings that mark a captcha page")
+	flag.IntVar(&warnDays, "warn-days", 0, "Warn about domains expiring within `days` (0 disables, a warn=N directive in the -f file overrides it)")
+	checkNS := flag.String("check-ns-match", "", "Fail domains whose nameservers differ from this comma-separated `list` (needs -method whois43 or rdap)")
+	langName := flag.String("lang", "en", "Language of the report lines: en or cs")
+	flag.StringVar(&outputFormat, "format", "text", "Output `format`: text, jsonl (one JSON object per line), json (an array at the end) or markdown (a table at the end)")
+	fields := flag.String("output-fields", "", "Comma-separated `list` of the fields, in order, of the jsonl, json and markdown output (domain, status, expiration, days_left, registrar, ...)")
+	flag.BoolVar(&jsonPretty, "json-pretty", false, "Indent the array of -format json")
+	onlyChangedExit := flag.Bool("only-changed-exit", false, "With -compare-with, exit 0 if nothing changed, 1 if something did, 2 if a check failed")
+	compareWith := flag.String("compare-with", "", "Print only the changes against the results in a previous jsonl `file`")
+	selectField := flag.String("select", "", "Print only this `field` of each domain (expiration, created, registrar, nameservers, ...)")
+	flag.BoolVar(&http1Only, "http1", false, "Talk HTTP/1.1 to the registry, for networks where HTTP/2 stalls")
+	smtpHost := flag.String("smtp-host", "", "Mail a report of the batch through this SMTP `host:port`")
+	smtpFrom := flag.String("smtp-from", "", "Sender `address` of the -smtp-host report")
+	smtpTo := flag.String("smtp-to", "", "Comma-separated recipient `addresses` of the -smtp-host report")
+	smtpUser := flag.String("smtp-user", "", "SMTP `user` to authenticate as, with the password in $"+SMTPPasswordEnv)
+	pipeTo := flag.String("pipe-to", "", "Stream the jsonl results of the run to the stdin of this shell `command`, and wait for it")
+	smtpWhen := flag.String("smtp-when", "noteworthy", "Mail the report `always`, or if noteworthy: a failure, a mismatch or an expiring domain")
+	proxy := flag.String("proxy", "", "Send the http queries through this proxy `URL` instead of the one of HTTPS_PROXY")
+	proxyNetrc := flag.String("proxy-netrc", "", "Read the proxy login from this netrc `file` (the machine of the proxy host, or default)")
+	firstN := flag.Int("first-n", 0, "Check only the first `k` distinct domains of the input")
+	dryRun := flag.Bool("dry-run", false, "Print how each input normalizes to the domain checked, without querying anything")
+	normalizeOnly := flag.Bool("normalize-only", false, "Print just the domain each input normalizes to, one per line, without querying anything")
+	validateOnly := flag.Bool("validate-only", false, "Print how many of the inputs are valid, rejected or duplicates, without querying anything")
+	histogram := flag.Bool("histogram", false, "After a batch, chart on stderr how many domains expire within each range of -histogram-buckets")
+	histogramBuckets := flag.String("histogram-buckets", HistogramBuckets, "Comma-separated ascending `days` bounding the ranges of -histogram")
+	groupBy := flag.String("group-by", "", "After a batch, summarize the registered domains by `field` (registrar or nameserver) on stderr")
+	assert := flag.String("assert", "", "Exit 0 only if the single domain is in this `state` (free, taken, expired), 3 otherwise")
+	singleExitCodes := flag.Bool("single-exit-codes", false, "Exit 0 if the single domain is free, 10 if it's taken, 11 if the check failed")
+	expirationOnly := flag.Bool("expiration-only", false, "Print only the expiration date of a single domain, exit non-zero if it's free")
+	healthcheckMode := flag.Bool("healthcheck", false, "Check that "+HealthcheckDomain+" can be queried and parsed, exit non-zero if not")
+	flag.StringVar(&sessionCookie, "session-cookie", "", "Send `name=value` cookies with every request (default $"+SessionCookieEnv+")")
+	persistCookies := flag.Bool("persist-cookies", false, "Keep the registry's cookies across runs, so a solved captcha carries over")
+	showVersion := flag.Bool("version", false, "Print the version and the nic.cz page layout it parses, then exit")
+	completion := flag.String("completion", "", "Print a completion script for `shell` (bash, zsh or fish)")
+	rate := flag.Float64("rate", float64(time.Second)/float64(Politeness), "Maximum `requests` per second sent to the registry")
+	flag.Parse()
+	if *showVersion {
+		fmt.Printf("czdomain %s (nic.cz layout %s)\n", Version, LayoutRevision)
+		return
+	}
+
+	if *completion != "" {
+		if err := printCompletion(os.Stdout, *completion); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	// Validate all the flags before anything is read, opened or sent, so
+	// that a usage error or an informational flag never has side effects.
+
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	if *profile != "" {
+		if err := applyProfile(*profile, explicit); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	if *concurrency < 1 {
+		fmt.Fprintln(os.Stderr, "The concurrency must be at least 1")
+		os.Exit(2)
+	}
+
+	if *autoTune && *concurrency == 1 {
+		fmt.Fprintln(os.Stderr, "-auto-tune adapts up to -concurrency, set it above 1")
+		os.Exit(2)
+	}
+
+	if *tz != "" {
+		zone, err := time.LoadLocation(*tz)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unknown time zone %q\n", *tz)
+			os.Exit(2)
+		}
+		reportZone = zone
+	}
+
+	var report *mailReport
+	if *smtpHost != "" {
+		if *smtpFrom == "" || *smtpTo == "" {
+			fmt.Fprintln(os.Stderr, "-smtp-host needs -smtp-from and -smtp-to")
+			os.Exit(2)
+		}
+		if *smtpWhen != "always" && *smtpWhen != "noteworthy" {
+			fmt.Fprintf(os.Stderr, "Unknown -smtp-when %q, use always or noteworthy\n", *smtpWhen)
+			os.Exit(2)
+		}
+		if *interactive || *refreshInterval > 0 {
+			fmt.Fprintln(os.Stderr, "-smtp-host mails the report of a batch, it doesn't go with -i or -refresh-interval")
+			os.Exit(2)
+		}
+		if _, _, err := net.SplitHostPort(*smtpHost); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid SMTP host %q, expected host:port\n", *smtpHost)
+			os.Exit(2)
+		}
+		report = &mailReport{host: *smtpHost, from: *smtpFrom, to: strings.Split(*smtpTo, ","), user: *smtpUser, when: *smtpWhen}
+	}
+
+	if runRetries.limit < 0 {
+		fmt.Fprintln(os.Stderr, "-retry-budget can't be negative")
+		os.Exit(2)
+	}
+
+	if *firstN < 0 {
+		fmt.Fprintln(os.Stderr, "-first-n can't be negative")
+		os.Exit(2)
+	}
+
+	if batchSize < 0 {
+		fmt.Fprintln(os.Stderr, "The batch size can't be negative")
+		os.Exit(2)
+	}
+
+	if *rate <= 0 {
+		fmt.Fprintln(os.Stderr, "The rate must be positive")
+		os.Exit(2)
+	}
+
+	if lang = languages[*langName]; lang == nil {
+		fmt.Fprintf(os.Stderr, "Unknown language %q\n", *langName)
+		os.Exit(2)
+	}
+
+	if outputFormat != "text" && outputFormat != "jsonl" && !batchFormat() {
+		fmt.Fprintf(os.Stderr, "Unknown output format %q\n", outputFormat)
+		os.Exit(2)
+	}
+
+	if batchFormat() && (*interactive || *refreshInterval > 0) {
+		fmt.Fprintf(os.Stderr, "-format %s writes the results at the end of a batch, use text or jsonl with -i or -refresh-interval\n", outputFormat)
+		os.Exit(2)
+	}
+
+	if *fields != "" {
+		var err error
+		if outputFields, err = parseOutputFields(*fields); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	bounds, err := parseBuckets(*histogramBuckets)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if _, ok := groupKeys[*groupBy]; *groupBy != "" && !ok {
+		fmt.Fprintf(os.Stderr, "Unknown field %q, -group-by takes registrar or nameserver\n", *groupBy)
+		os.Exit(2)
+	}
+
+	if _, ok := assertions[*assert]; *assert != "" && !ok {
+		fmt.Fprintf(os.Stderr, "Unknown state %q, -assert takes one of: %s\n", *assert, strings.Join(assertionNames(), ", "))
+		os.Exit(2)
+	}
+
+	if *onlyChangedExit && *compareWith == "" {
+		fmt.Fprintln(os.Stderr, "-only-changed-exit tells whether -compare-with found changes, it needs -compare-with")
+		os.Exit(2)
+	}
+
+	if *singleExitCodes {
+		for name, set := range map[string]bool{
+			"-assert":          *assert != "",
+			"-compare-with":    *compareWith != "",
+			"-check-ns-match":  *checkNS != "",
+			"-expiration-only": *expirationOnly,
+		} {
+			if set {
+				fmt.Fprintf(os.Stderr, "-single-exit-codes has its own exit codes, it doesn't go with %s\n", name)
+				os.Exit(2)
+			}
+		}
+	}
+
+	if _, ok := selectors[*selectField]; *selectField != "" && !ok {
+		fmt.Fprintf(os.Stderr, "Unknown field %q, -select takes one of: %s\n", *selectField, strings.Join(selectorNames(), ", "))
+		os.Exit(2)
+	}
+
+	if *proxy != "" {
+		u, err := url.Parse(*proxy)
+		if err != nil || u.Host == "" {
+			fmt.Fprintf(os.Stderr, "Invalid proxy %q\n", *proxy)
+			os.Exit(2)
+		}
+		if u.User != nil {
+			log.Println("Warning: the -proxy credentials show in the process list, use -proxy-netrc")
+		}
+		proxyURL = u
+	}
+
+	var sinceTime time.Time
+	if *since != "" {
+		if *historyFile == "" {
+			fmt.Fprintln(os.Stderr, "-since reads the -history file, give it its path")
+			os.Exit(2)
+		}
+		var err error
+		if sinceTime, err = parseSince(*since, time.Now()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	if *listCheckersMode {
+		listCheckers(os.Stdout)
+		os.Exit(0)
+	}
+
+	// The environment file is the first one read: the flags not depending on
+	// it are validated above, the endpoints and the method it may set here.
+	env, err := loadEnvironment(*envFile, *envName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	env.apply(explicit)
+
+	if _, ok := methods[method]; !ok {
+		fmt.Fprintf(os.Stderr, "Unknown method %q\n", method)
+		os.Exit(2)
+	}
+
+	if *checkNS != "" && method == "http" {
+		fmt.Fprintln(os.Stderr, "The web page doesn't list the nameservers, -check-ns-match needs -method whois43 or rdap")
+		os.Exit(2)
+	}
+
+	if report != nil {
+		log.SetOutput(report)
+	}
+
+	limiter = newRateLimiter(*rate)
+	limiter.step, limiter.maxPenalty = *delayOnError, *maxErrorDelay
+
+	captchaMarkers = strings.Split(*markers, ",")
+
+	if *checkNS != "" {
+		expectedNameservers = strings.Split(*checkNS, ",")
+	}
+
+	if sessionCookie == "" {
+		sessionCookie = os.Getenv(SessionCookieEnv)
+	}
+
+	if *proxyNetrc != "" {
+		logins, err := readNetrc(*proxyNetrc)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Can't read the proxy credentials:", err)
+			os.Exit(2)
+		}
+		proxyLogins = logins
+	}
+
+	httpClient = newHTTPClient()
+
+	if *persistCookies {
+		path, err := cookieFilePath()
+		if err == nil {
+			httpClient.Jar, err = loadCookieJar(path)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Can't load the cookies:", err)
+			os.Exit(2)
+		}
+	}
+
+	var previous map[string]jsonResult
+	if *compareWith != "" {
+		var err error
+		if previous, err = readResults(*compareWith); err != nil {
+			fmt.Fprintln(os.Stderr, "Can't read the previous results:", err)
+			os.Exit(2)
+		}
+		collected = &collector{}
+		reportResults = false
+	}
+
+	if *summaryJSON || *groupBy != "" || *histogram || report != nil || (batchFormat() && previous == nil) {
+		if collected == nil {
+			collected = &collector{}
+		}
+	}
+
+	if batchFormat() {
+		reportResults = false
+	}
+
+	if *since != "" {
+		changes, err := historyChanges(*historyFile, *historyKeep, sinceTime)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Can't read the history file:", err)
+			os.Exit(2)
+		}
+		reportStatusChanges(os.Stdout, changes)
+		if len(changes) > 0 {
+			os.Exit(ExitChanged)
+		}
+		os.Exit(ExitOK)
+	}
+
+	if *healthcheckMode {
+		os.Exit(healthcheck())
+	}
+
+	urls := flag.Args()
+	var fromFiles []string
+	for _, path := range inputFiles {
+		domains, err := readDomainsFile(path, *inputFormat)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+
+		for _, domain := range domains {
+			if len(inputFiles) > 1 {
+				sources[domain] = append(sources[domain], path)
+			}
+			if len(sources[domain]) <= 1 {
+				fromFiles = append(fromFiles, domain)
+			}
+		}
+	}
+	urls = append(fromFiles, urls...)
+
+	// Without any domains, read them from a pipe or prompt for them on a
+	// terminal.
+	if len(urls) == 0 && len(inputFiles) == 0 && !*interactive {
+		if isPiped(os.Stdin) {
+			domains, err := readDomainsFile("-", *inputFormat)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			urls = domains
+		} else if isTerminal(os.Stdin) && !isDevNull(os.Stdin) && *refreshInterval == 0 {
+			*interactive = true
+		}
+	}
+
+	if *tlds != "" {
+		urls = expandTLDs(urls, strings.Split(*tlds, ","))
+	}
+
+	if *firstN > 0 {
+		urls = firstDomains(urls, *firstN)
+	}
+
+	if *dryRun {
+		os.Exit(printNormalized(os.Stdout, urls))
+	}
+
+	if *validateOnly {
+		os.Exit(validateInput(os.Stdout, urls))
+	}
+
+	if *normalizeOnly {
+		os.Exit(printNormalizedOnly(os.Stdout, urls))
+	}
+
+	if *expirationOnly {
+		if len(urls) != 1 {
+			fmt.Fprintln(os.Stderr, "-expiration-only takes exactly one domain")
+			os.Exit(2)
+		}
+		os.Exit(printSelected(urls, "expiration"))
+	}
+
+	if *assert != "" {
+		if len(urls) != 1 {
+			fmt.Fprintln(os.Stderr, "-assert takes exactly one domain")
+			os.Exit(2)
+		}
+		os.Exit(assertStatus(urls[0], *assert))
+	}
+
+	if *singleExitCodes {
+		if len(urls) != 1 {
+			fmt.Fprintln(os.Stderr, "-single-exit-codes takes exactly one domain")
+			os.Exit(2)
+		}
+		os.Exit(singleExitCode(urls[0]))
+	}
+
+	if *selectField != "" {
+		if len(urls) == 0 {
+			printUsage()
+			os.Exit(2)
+		}
+		os.Exit(printSelected(urls, *selectField))
+	}
+
+	if *historyFile != "" {
+		var err error
+		if history, err = openHistory(*historyFile, *historyMaxSize, *historyKeep); err != nil {
+			fmt.Fprintln(os.Stderr, "Can't open the history file:", err)
+			os.Exit(2)
+		}
+	}
+
+	if *pipeTo != "" {
+		var err error
+		if resultPipe, err = startPipe(*pipeTo); err != nil {
+			fmt.Fprintln(os.Stderr, "Can't start the -pipe-to command:", err)
+			os.Exit(2)
+		}
+	}
+
+	start := time.Now()
+	changesFound := false
+
+	if *interactive {
+		fmt.Println("Type :help for the commands, :quit or Ctrl-D to quit.")
+		startInteractiveLoop()
+	} else {
+		if len(urls) > 0 && *refreshInterval > 0 {
+			startRefreshLoop(urls, *refreshInterval)
+		} else if len(urls) > 0 {
+			ctx := context.Background()
+			if maxRuntime > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, maxRuntime)
+				defer cancel()
+			}
+
+			if *autoTune {
+				tuner = newAutoTuner(*concurrency)
+			}
+			startArgLoop(ctx, urls, *showStats, *concurrency, *ordered)
+
+			if previous != nil && reportChanges(os.Stdout, previous, collected.outcomes) > 0 {
+				changesFound = true
+				if !*onlyChangedExit {
+					setExitCode(ExitChanged)
+				}
+			} else if previous == nil && outputFormat == "json" {
+				writeJSONArray(os.Stdout, collected.outcomes)
+			} else if previous == nil && outputFormat == "markdown" {
+				writeMarkdownTable(os.Stdout, collected.outcomes, time.Now())
+			}
+
+			if *groupBy != "" {
+				writeGroups(os.Stderr, collected.outcomes, *groupBy)
+			}
+
+			if *histogram {
+				writeHistogram(os.Stderr, collected.outcomes, bounds, time.Now())
+			}
+		} else {
+			printUsage()
+		}
+	}
+
+	history.close()
+	captchas.flush()
+	resultPipe.close()
+
+	if *onlyChangedExit {
+		exitCode = changeExitCode(exitCode, changesFound)
+	}
+
+	if *summaryJSON {
+		writeSummary(os.Stderr, collected.outcomes, time.Since(start), exitCode)
+	}
+
+	if report != nil {
+		report.send(collected.outcomes, time.Since(start), exitCode)
+	}
+
+	os.Exit(exitCode)
 }
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ProductionEnv is the built-in -env, the public services of nic.cz.
+const ProductionEnv = "production"
+
+// environment is a registry deployment -env points the checker at, such as a
+// staging or OTE one of an integrator, described in the environments file.
+// Empty fields keep the defaults.
+type environment struct {
+	BaseURL     string        `json:"base_url"`
+	WhoisPath   string        `json:"whois_path"`
+	WhoisServer string        `json:"whois_server"`
+	RDAPURL     string        `json:"rdap_url"`
+	Method      string        `json:"method"`
+	Haystacks   pageHaystacks `json:"haystacks"`
+}
+
+// environmentsPath is the default -env-file, environments.json in the
+// czdomain directory of the user's config directory.
+func environmentsPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "czdomain", "environments.json")
+}
+
+// loadEnvironment returns the environment called name in the JSON object of
+// path, which maps the names to their settings. The production one needs no
+// file.
+func loadEnvironment(path, name string) (environment, error) {
+	environments := map[string]environment{}
+
+	content, err := os.ReadFile(path)
+	switch {
+	case err != nil && name == ProductionEnv:
+		return environment{}, nil
+	case err != nil:
+		return environment{}, fmt.Errorf("Can't read the environments of -env %s: %v", name, err)
+	}
+
+	if err := json.Unmarshal(content, &environments); err != nil {
+		return environment{}, fmt.Errorf("Can't parse %s: %v", path, err)
+	}
+
+	env, ok := environments[name]
+	if !ok && name != ProductionEnv {
+		names := []string{ProductionEnv}
+		for known := range environments {
+			if known != ProductionEnv {
+				names = append(names, known)
+			}
+		}
+		sort.Strings(names[1:])
+		return environment{}, fmt.Errorf("Unknown -env %q, %s defines: %s", name, path, strings.Join(names, ", "))
+	}
+
+	return env, nil
+}
+
+// apply points the checker at the environment. The settings whose flags were
+// set on the command line, as listed in explicit, keep the flag value.
+func (e environment) apply(explicit map[string]bool) {
+	for _, setting := range []struct {
+		flag  string
+		value string
+		to    *string
+	}{
+		{"base-url", e.BaseURL, &baseURL},
+		{"whois-path", e.WhoisPath, &whoisPath},
+		{"whois-server", e.WhoisServer, &whoisServer},
+		{"rdap-url", e.RDAPURL, &rdapServer},
+		{"method", e.Method, &method},
+	} {
+		if setting.value != "" && !explicit[setting.flag] {
+			*setting.to = setting.value
+		}
+	}
+
+	for _, haystack := range []struct {
+		value string
+		to    *string
+	}{
+		{e.Haystacks.Free, &haystacks.Free},
+		{e.Haystacks.Reserved, &haystacks.Reserved},
+		{e.Haystacks.Expiration, &haystacks.Expiration},
+		{e.Haystacks.Status, &haystacks.Status},
+	} {
+		if haystack.value != "" {
+			*haystack.to = haystack.value
+		}
+	}
+	if e.Haystacks.ExpirationOffset > 0 {
+		haystacks.ExpirationOffset = e.Haystacks.ExpirationOffset
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OutputFields are the names -output-fields accepts: the keys of the
+// structured output, url as another name of domain, and days_left.
+var OutputFields = []string{
+	"domain", "url", "free", "status", "reserved", "expiration", "days_left", "raw_expiration",
+	"drop_date", "drop_date_estimated", "registered", "nameservers", "keyset", "registrar", "contact_email",
+	"statuses", "method", "final_url", "ns_mismatch", "fields", "source", "error",
+}
+
+// outputFields are the fields of -output-fields in their order, nil for all
+// the fields of the format.
+var outputFields []string
+
+// parseOutputFields reads the comma-separated list of -output-fields.
+func parseOutputFields(list string) ([]string, error) {
+	var fields []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if !containsString(OutputFields, name) {
+			return nil, fmt.Errorf("Unknown field %q, -output-fields takes: %s", name, strings.Join(OutputFields, ", "))
+		}
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonField is a key and its encoded value.
+type jsonField struct {
+	name  string
+	value json.RawMessage
+}
+
+// fieldObject is a JSON object keeping the order of its keys.
+type fieldObject []jsonField
+
+func (o fieldObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(field.name)
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(field.value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// selectFields returns r with only the -output-fields, in their order, a
+// missing value being null. The error of a failed check is kept even when
+// not selected, so it can't pass for a result.
+func selectFields(r jsonResult, now time.Time) fieldObject {
+	data, _ := json.Marshal(r)
+	values := map[string]json.RawMessage{}
+	json.Unmarshal(data, &values)
+
+	values["url"] = values["domain"]
+	if expiration, err := time.Parse(DateFormat, r.Expiration); err == nil {
+		result := CheckResult{Expiration: expiration}
+		values["days_left"] = json.RawMessage(strconv.Itoa(result.daysLeft(now)))
+	}
+
+	object := fieldObject{}
+	for _, name := range outputFields {
+		value, ok := values[name]
+		if !ok {
+			value = json.RawMessage("null")
+		}
+		object = append(object, jsonField{name, value})
+	}
+	if r.Error != "" && !containsString(outputFields, "error") {
+		object = append(object, jsonField{"error", values["error"]})
+	}
+
+	return object
+}
+
+// structured returns r as written in the structured output: as it is, or
+// reduced to the -output-fields.
+func structured(r jsonResult) interface{} {
+	if outputFields == nil {
+		return r
+	}
+	return selectFields(r, time.Now())
+}
+
+// fieldText renders a value of a fieldObject as a table cell: strings
+// unquoted, lists comma-separated, the response fields as key=value, null
+// empty.
+func fieldText(value json.RawMessage) string {
+	var s string
+	if json.Unmarshal(value, &s) == nil {
+		return s
+	}
+
+	var list []string
+	if json.Unmarshal(value, &list) == nil {
+		return strings.Join(list, ", ")
+	}
+
+	var pairs []Field
+	if json.Unmarshal(value, &pairs) == nil {
+		for _, pair := range pairs {
+			list = append(list, pair.Key+"="+pair.Value)
+		}
+		return strings.Join(list, ", ")
+	}
+
+	if string(value) == "null" {
+		return ""
+	}
+	return string(value)
+}
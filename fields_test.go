@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSelectFieldsDaysLeftIgnoresLocalZone(t *testing.T) {
+	result := &CheckResult{URL: "example.cz", Expiration: time.Date(2027, 3, 15, 0, 0, 0, 0, time.UTC), Status: StatusRegistered}
+	now := time.Date(2027, 3, 9, 23, 30, 0, 0, time.UTC)
+
+	override(t, &outputFields, []string{"days_left"})
+	for _, zone := range []*time.Location{
+		time.UTC,
+		time.FixedZone("America/New_York", -4*60*60),
+		time.FixedZone("Europe/Prague", 2*60*60),
+	} {
+		override(t, &time.Local, zone)
+
+		object := selectFields(toJSON(result), now)
+		var days int
+		if err := json.Unmarshal(object[0].value, &days); err != nil || days != result.daysLeft(now) {
+			t.Errorf("days_left in %s = %s, want %d like the report", zone, object[0].value, result.daysLeft(now))
+		}
+	}
+}
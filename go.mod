@@ -0,0 +1,3 @@
+module github.com/mrtnmch/czdomain
+
+go 1.21
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// groupKeys extract the keys -group-by sorts the registered domains by; a
+// domain with several (nameservers) counts in each group.
+var groupKeys = map[string]func(r *CheckResult) []string{
+	"registrar": func(r *CheckResult) []string { return []string{r.Registrar} },
+	"nameserver": func(r *CheckResult) []string {
+		var keys []string
+		for _, ns := range r.Nameservers {
+			keys = append(keys, strings.ToLower(ns))
+		}
+		return keys
+	},
+}
+
+// domainGroup is a registrar or a nameserver with the domains it has.
+type domainGroup struct {
+	key     string
+	domains []*CheckResult
+}
+
+// groupResults groups the registered domains among outcomes by the key of
+// by. Free, reserved and failed domains are left out, as are those without
+// the field, which are returned as missing.
+func groupResults(outcomes []outcome, by string) ([]domainGroup, []string) {
+	groups := map[string]*domainGroup{}
+	var missing []string
+
+	for _, o := range outcomes {
+		if o.err != nil || o.result.IsFree || o.result.Status == StatusReserved {
+			continue
+		}
+
+		keys := groupKeys[by](o.result)
+		if len(keys) == 0 || keys[0] == "" {
+			missing = append(missing, o.result.URL)
+			continue
+		}
+
+		for _, key := range keys {
+			if groups[key] == nil {
+				groups[key] = &domainGroup{key: key}
+			}
+			groups[key].domains = append(groups[key].domains, o.result)
+		}
+	}
+
+	sorted := make([]domainGroup, 0, len(groups))
+	for _, group := range groups {
+		sort.Slice(group.domains, func(i, j int) bool {
+			return group.domains[i].Expiration.Before(group.domains[j].Expiration)
+		})
+		sorted = append(sorted, *group)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i].domains) != len(sorted[j].domains) {
+			return len(sorted[i].domains) > len(sorted[j].domains)
+		}
+		return sorted[i].key < sorted[j].key
+	})
+
+	return sorted, missing
+}
+
+// writeGroups writes the -group-by report: a tab-separated line per group,
+// the largest first, with its number of domains and the nearest expirations.
+func writeGroups(w io.Writer, outcomes []outcome, by string) {
+	groups, missing := groupResults(outcomes, by)
+
+	for _, group := range groups {
+		var nearest []string
+		for _, result := range group.domains[:min(len(group.domains), 3)] {
+			nearest = append(nearest, result.URL+" "+formatDate(result.Expiration))
+		}
+
+		fmt.Fprintf(w, "%s\t%s\tnearest: %s\n", group.key, lang.number(len(group.domains)), strings.Join(nearest, ", "))
+	}
+
+	if len(missing) > 0 {
+		fmt.Fprintf(w, "(no %s)\t%s\t%s\n", by, lang.number(len(missing)), strings.Join(missing, ", "))
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// HealthcheckDomain is a long-registered domain used to verify that the
+// registry is reachable and its responses still parse.
+const HealthcheckDomain = "nic.cz"
+
+// healthcheck checks HealthcheckDomain and returns the exit code for it.
+func healthcheck() int {
+	start := time.Now()
+	result, err := CheckURL(HealthcheckDomain)
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	switch {
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "unhealthy: %s: %s\n", HealthcheckDomain, err)
+		return ExitCheckFailed
+	case result.IsFree || result.Expiration.IsZero():
+		fmt.Fprintf(os.Stderr, "unhealthy: %s parsed without an expiration\n", HealthcheckDomain)
+		return ExitCheckFailed
+	}
+
+	fmt.Printf("ok: %s expires %s (%s, %s)\n", HealthcheckDomain, result.Expiration.Format("2006-01-02"), result.Method, elapsed)
+	return ExitOK
+}
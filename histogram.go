@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistogramBuckets is the default of -histogram-buckets, the bounds in days
+// of the expiration ranges of -histogram.
+const HistogramBuckets = "30,90,365"
+
+// HistogramWidth is the length of the longest bar of -histogram.
+const HistogramWidth = 40
+
+// parseBuckets parses the comma-separated -histogram-buckets, which must be
+// positive and ascending.
+func parseBuckets(list string) ([]int, error) {
+	var bounds []int
+
+	for _, value := range strings.Split(list, ",") {
+		days, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("Invalid bucket %q, -histogram-buckets takes positive numbers of days", value)
+		}
+		if len(bounds) > 0 && days <= bounds[len(bounds)-1] {
+			return nil, fmt.Errorf("The -histogram-buckets must be ascending, %d follows %d", days, bounds[len(bounds)-1])
+		}
+		bounds = append(bounds, days)
+	}
+
+	return bounds, nil
+}
+
+// histogramRow is a bar of -histogram.
+type histogramRow struct {
+	label string
+	count int
+}
+
+// expirationHistogram counts the outcomes into the expiration ranges between
+// bounds, after those that already expired and before the free ones. The
+// reserved and failed domains get rows of their own when there are any.
+func expirationHistogram(outcomes []outcome, bounds []int, now time.Time) []histogramRow {
+	rows := []histogramRow{{label: "expired"}}
+	for i, bound := range bounds {
+		if i == 0 {
+			rows = append(rows, histogramRow{label: fmt.Sprintf("< %d days", bound)})
+		} else {
+			rows = append(rows, histogramRow{label: fmt.Sprintf("%d-%d days", bounds[i-1], bound)})
+		}
+	}
+	rows = append(rows, histogramRow{label: fmt.Sprintf(">= %d days", bounds[len(bounds)-1])})
+	free, reserved, failed := histogramRow{label: "free"}, histogramRow{label: "reserved"}, histogramRow{label: "failed"}
+
+	for _, o := range outcomes {
+		switch {
+		case o.err != nil:
+			failed.count++
+		case o.result.Status == StatusReserved:
+			reserved.count++
+		case o.result.IsFree:
+			free.count++
+		case o.result.Status == StatusExpired || o.result.Status == StatusProtected:
+			rows[0].count++
+		default:
+			// The first bound above the days left closes its range.
+			rows[1+sort.SearchInts(bounds, o.result.daysLeft(now)+1)].count++
+		}
+	}
+
+	rows = append(rows, free)
+	for _, row := range []histogramRow{reserved, failed} {
+		if row.count > 0 {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+// writeHistogram writes the -histogram report: a line per range with its
+// number of domains and a bar scaled to HistogramWidth.
+func writeHistogram(w io.Writer, outcomes []outcome, bounds []int, now time.Time) {
+	rows := expirationHistogram(outcomes, bounds, now)
+
+	most, labelWidth := 0, 0
+	for _, row := range rows {
+		most = max(most, row.count)
+		labelWidth = max(labelWidth, len(row.label))
+	}
+
+	for _, row := range rows {
+		bar := 0
+		if most > 0 {
+			bar = (row.count*HistogramWidth + most - 1) / most
+		}
+		line := fmt.Sprintf("%-*s %6s %s", labelWidth, row.label, lang.number(row.count), strings.Repeat("#", bar))
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+}
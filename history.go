@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HistoryMaxSize is the default of -history-max-size, the size at which the
+// history file is rotated.
+const HistoryMaxSize = 10 << 20
+
+// HistoryKeep is the default of -history-keep, the number of rotated history
+// files kept next to the current one.
+const HistoryKeep = 5
+
+// historyEntry is a line of the -history file: a jsonl result with the time
+// of the check and how long it took. Lines written before latency_ms was
+// recorded lack it, as failed checks of those lack their method.
+type historyEntry struct {
+	Time      string `json:"time"`
+	LatencyMs int64  `json:"latency_ms,omitempty"`
+	jsonResult
+}
+
+// historyLog appends every check to a jsonl file. Once the file reaches
+// maxSize it's renamed to path.1 (shifting the older ones up to path.keep)
+// and a new one is started. Each line is a single append and rotation only
+// happens between lines, so a crash loses at most the line being written. A
+// nil historyLog records nothing.
+type historyLog struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	size    int64
+	maxSize int64
+	keep    int
+
+	// expirations are the last recorded expiration of each domain, to catch
+	// missed renewals.
+	expirations map[string]string
+}
+
+var history *historyLog
+
+func openHistory(path string, maxSize int64, keep int) (*historyLog, error) {
+	h := &historyLog{path: path, maxSize: maxSize, keep: keep, expirations: map[string]string{}}
+
+	err := replayHistory(path, keep, func(entry historyEntry, _ time.Time) {
+		if entry.Expiration != "" {
+			h.expirations[entry.Domain] = entry.Expiration
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.open(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *historyLog) open() error {
+	file, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	h.file, h.size = file, info.Size()
+	return nil
+}
+
+func (h *historyLog) record(o outcome) {
+	if h == nil {
+		return
+	}
+
+	entry := historyEntry{Time: time.Now().Format(time.RFC3339), LatencyMs: o.elapsed.Milliseconds()}
+	if o.err != nil {
+		entry.jsonResult = jsonResult{Domain: o.url, Method: method, Error: o.err.Error()}
+	} else {
+		entry.jsonResult = toJSON(o.result)
+		// The raw response fields would multiply the size of every line.
+		entry.Fields = nil
+	}
+
+	line, _ := json.Marshal(entry)
+	line = append(line, '\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if entry.Error == "" && entry.Expiration != "" {
+		h.expirations[entry.Domain] = entry.Expiration
+	}
+
+	if h.maxSize > 0 && h.size > 0 && h.size+int64(len(line)) > h.maxSize {
+		if err := h.rotate(); err != nil {
+			log.Printf("Can't rotate the history file: %v", err)
+		}
+	}
+
+	if h.file == nil {
+		return
+	}
+
+	n, err := h.file.Write(line)
+	h.size += int64(n)
+	if err != nil {
+		log.Printf("Can't write the history file: %v", err)
+	}
+}
+
+// RenewalDays is the default of -renewal-days, how close to its recorded
+// expiration a domain is expected to have been renewed.
+const RenewalDays = 14
+
+var renewalDays = RenewalDays
+
+// checkRenewal compares the expiration of a successful check to the one last
+// recorded for the domain, before the check is recorded. It warns about an
+// expiration that moved earlier, and about a domain within renewalDays of (or
+// past) its expiration that wasn't renewed, failing the run with
+// ExitMismatch.
+func (h *historyLog) checkRenewal(o outcome, now time.Time) {
+	if h == nil || o.err != nil || o.result.Expiration.IsZero() {
+		return
+	}
+
+	h.mu.Lock()
+	recorded, ok := h.expirations[o.result.URL]
+	h.mu.Unlock()
+
+	// Both are the registry's calendar days at midnight UTC.
+	previous, err := time.Parse(DateFormat, recorded)
+	if !ok || err != nil {
+		return
+	}
+
+	current := o.result.Expiration
+	switch {
+	case current.Before(previous):
+		log.Printf("%s\tWarning: the expiration moved earlier, from %s to %s", o.result.URL, recorded, formatDate(current))
+		setExitCode(ExitMismatch)
+	case renewalDays > 0 && !current.After(previous) && o.result.expiresBefore(now.AddDate(0, 0, renewalDays)):
+		if days := o.result.daysLeft(now); days < 0 {
+			log.Printf("%s\tWarning: not renewed, expired %d days ago on %s", o.result.URL, -days, recorded)
+		} else {
+			log.Printf("%s\tWarning: not renewed, expires in %d days on %s", o.result.URL, days, recorded)
+		}
+		setExitCode(ExitMismatch)
+	}
+}
+
+// rotate moves the current file to path.1 and opens a new one. If the rename
+// fails, it keeps appending to the current file.
+func (h *historyLog) rotate() error {
+	h.file.Sync()
+
+	if h.keep > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", h.path, h.keep))
+		for i := h.keep - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", h.path, i), fmt.Sprintf("%s.%d", h.path, i+1))
+		}
+		if err := os.Rename(h.path, h.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Truncate(h.path, 0); err != nil {
+		return err
+	}
+
+	h.file.Close()
+	h.file = nil
+	return h.open()
+}
+
+// close flushes the history file to disk.
+func (h *historyLog) close() {
+	if h == nil || h.file == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.file.Sync()
+	h.file.Close()
+	h.file = nil
+}
+
+// parseSince reads -since: a duration back from now (with d for days, e.g.
+// 7d), an RFC 3339 time or a date.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(DateFormat, value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid -since %q, expected a duration (36h, 7d), a time or a date", value)
+}
+
+// statusChange is a domain seen in a different status than at its previous
+// successful check.
+type statusChange struct {
+	Domain   string `json:"domain"`
+	From     Status `json:"from"`
+	To       Status `json:"to"`
+	Observed string `json:"observed"`
+}
+
+// historyFiles lists the files of the history at path, the oldest first.
+func historyFiles(path string, keep int) []string {
+	var files []string
+	for i := keep; i > 0; i-- {
+		rotated := fmt.Sprintf("%s.%d", path, i)
+		if _, err := os.Stat(rotated); err == nil {
+			files = append(files, rotated)
+		}
+	}
+	return append(files, path)
+}
+
+// replayHistory calls fn with each successful check of the history at path,
+// the oldest first, rotated files included. Lines that don't parse are
+// logged and skipped.
+func replayHistory(path string, keep int, fn func(entry historyEntry, observed time.Time)) error {
+	for _, name := range historyFiles(path, keep) {
+		file, err := os.Open(name)
+		if os.IsNotExist(err) && name == path {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(nil, 1<<20)
+		for n := 1; scanner.Scan(); n++ {
+			var entry historyEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				log.Printf("%s:%d: %v", name, n, err)
+				continue
+			}
+			if entry.Error != "" {
+				continue
+			}
+
+			observed, err := time.Parse(time.RFC3339, entry.Time)
+			if err != nil {
+				log.Printf("%s:%d: %v", name, n, err)
+				continue
+			}
+			fn(entry, observed)
+		}
+
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+// historyChanges returns the status changes of the history at path first
+// observed at or after since. The checks before since only set the status a
+// change is measured from; unknown statuses are skipped.
+func historyChanges(path string, keep int, since time.Time) ([]statusChange, error) {
+	last := map[string]Status{}
+	var changes []statusChange
+
+	err := replayHistory(path, keep, func(entry historyEntry, observed time.Time) {
+		if entry.Status == StatusUnknown {
+			return
+		}
+
+		previous, seen := last[entry.Domain]
+		last[entry.Domain] = entry.Status
+		if seen && previous != entry.Status && !observed.Before(since) {
+			changes = append(changes, statusChange{entry.Domain, previous, entry.Status, entry.Time})
+		}
+	})
+
+	return changes, err
+}
+
+// reportStatusChanges writes the changes of -since, as tab-separated text or
+// one JSON object each.
+func reportStatusChanges(w io.Writer, changes []statusChange) {
+	for _, change := range changes {
+		if outputFormat != "text" {
+			writeJSONLine(w, change)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", change.Domain, change.From, change.To, change.Observed)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckRenewalIgnoresLocalZone(t *testing.T) {
+	expiration := time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC)
+
+	for _, zone := range []*time.Location{
+		time.UTC,
+		time.FixedZone("America/New_York", -4*60*60),
+		time.FixedZone("Europe/Prague", 2*60*60),
+	} {
+		for _, tc := range []struct {
+			name     string
+			current  time.Time
+			now      time.Time
+			wantCode int
+		}{
+			{"unchanged", expiration, expiration.AddDate(0, -6, 0), ExitOK},
+			{"renewed", expiration.AddDate(1, 0, 0), expiration.AddDate(0, 0, -4), ExitOK},
+			{"not renewed", expiration, expiration.AddDate(0, 0, -4).Add(12 * time.Hour), ExitMismatch},
+			{"moved earlier", expiration.AddDate(0, 0, -1), expiration.AddDate(0, -6, 0), ExitMismatch},
+		} {
+			override(t, &time.Local, zone)
+			override(t, &exitCode, ExitOK)
+
+			h := &historyLog{expirations: map[string]string{"example.cz": formatDate(expiration)}}
+			h.checkRenewal(outcome{url: "example.cz", result: &CheckResult{URL: "example.cz", Expiration: tc.current, Status: StatusRegistered}}, tc.now)
+
+			if exitCode != tc.wantCode {
+				t.Errorf("%s in %s: exit code %d, want %d", tc.name, zone, exitCode, tc.wantCode)
+			}
+		}
+	}
+}
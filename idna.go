@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// unicodeNames renders the xn-- labels of the domains in the text report in
+// their Unicode form, see -unicode. Queries always use the ASCII form.
+var unicodeNames bool
+
+// displayName returns domain as the text report shows it: with -unicode its
+// punycode labels decoded, háčky.cz rather than xn--hky-ela4t.cz. A label
+// that doesn't decode stays as it is.
+func displayName(domain string) string {
+	if !unicodeNames || !strings.Contains(domain, "xn--") {
+		return domain
+	}
+
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		if encoded, ok := strings.CutPrefix(label, "xn--"); ok {
+			if decoded, err := decodePunycode(encoded); err == nil {
+				labels[i] = decoded
+			}
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// The parameters of Punycode, RFC 3492 section 5.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+var errPunycode = errors.New("Invalid punycode")
+
+// decodePunycode decodes a label without its xn-- prefix, RFC 3492 section
+// 6.2.
+func decodePunycode(encoded string) (string, error) {
+	var output []rune
+	if dash := strings.LastIndexByte(encoded, '-'); dash >= 0 {
+		for _, r := range encoded[:dash] {
+			if r >= utf8.RuneSelf {
+				return "", errPunycode
+			}
+			output = append(output, r)
+		}
+		encoded = encoded[dash+1:]
+	}
+
+	n, bias, i := punyInitialN, punyInitialBias, 0
+	for pos := 0; pos < len(encoded); {
+		oldI, w := i, 1
+		for k := punyBase; ; k += punyBase {
+			if pos >= len(encoded) {
+				return "", errPunycode
+			}
+			digit := punyDigit(encoded[pos])
+			pos++
+			if digit < 0 || digit > (1<<31-1-i)/w {
+				return "", errPunycode
+			}
+			i += digit * w
+
+			t := k - bias
+			if t < punyTMin {
+				t = punyTMin
+			} else if t > punyTMax {
+				t = punyTMax
+			}
+			if digit < t {
+				break
+			}
+			w *= punyBase - t
+		}
+
+		bias = punyAdapt(i-oldI, len(output)+1, oldI == 0)
+		n += i / (len(output) + 1)
+		i %= len(output) + 1
+		if n > utf8.MaxRune {
+			return "", errPunycode
+		}
+
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = rune(n)
+		i++
+	}
+
+	// A label of basic code points only would have no reason to be encoded.
+	decoded := string(output)
+	if len(decoded) == len(output) {
+		return "", errPunycode
+	}
+	return decoded, nil
+}
+
+func punyDigit(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c-'0') + 26
+	case c >= 'a' && c <= 'z':
+		return int(c - 'a')
+	case c >= 'A' && c <= 'Z':
+		return int(c - 'A')
+	}
+	return -1
+}
+
+func punyAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
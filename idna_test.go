@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func TestDecodePunycode(t *testing.T) {
+	for _, tc := range []struct {
+		encoded, want string
+	}{
+		{"hky-ela4t", "háčky"},
+		{"pli-rma35ctb", "příliš"},
+		{"luouk-uva4it5a4g", "žluťoučký"},
+		{"k-qla0j", "kůň"},
+		{"etina-gya30d", "čeština"},
+		{"1caql6dzd0drw5bzo", "ěščřžýáíé"},
+		{"bcher-kva", "bücher"},
+	} {
+		if got, err := decodePunycode(tc.encoded); err != nil || got != tc.want {
+			t.Errorf("decodePunycode(%q) = %q, %v, want %q", tc.encoded, got, err, tc.want)
+		}
+	}
+}
+
+func TestDecodePunycodeRejects(t *testing.T) {
+	for _, encoded := range []string{
+		"",       // nothing to decode
+		"abc-",   // basic code points only
+		"hky-",   // likewise
+		"hky-el", // cut in the middle of a delta
+		"hky-e!a4t",
+		"há-ela4t",
+		"99999999999999",
+	} {
+		if got, err := decodePunycode(encoded); err == nil {
+			t.Errorf("decodePunycode(%q) = %q, want an error", encoded, got)
+		}
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	override(t, &unicodeNames, true)
+
+	for _, tc := range []struct {
+		domain, want string
+	}{
+		{"xn--hky-ela4t.cz", "háčky.cz"},
+		{"example.cz", "example.cz"},
+		{"xn--hky-el.cz", "xn--hky-el.cz"},
+	} {
+		if got := displayName(tc.domain); got != tc.want {
+			t.Errorf("displayName(%q) = %q, want %q", tc.domain, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileList collects the values of a repeated flag, like -f.
+type fileList []string
+
+func (l *fileList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *fileList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// readDomainsFile reads the domains to check from path, "-" being stdin.
+func readDomainsFile(path, format string) ([]string, error) {
+	if path == "-" {
+		return readDomains(os.Stdin, format)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	domains, err := readDomains(file, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return domains, nil
+}
+
+// isPiped tells whether f is a pipe or a redirected file, as opposed to a
+// terminal or /dev/null.
+func isPiped(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && (info.Mode()&os.ModeNamedPipe != 0 || info.Mode().IsRegular())
+}
+
+// isDevNull tells whether f is the null device, which looks like a terminal
+// to isTerminal.
+func isDevNull(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	null, err := os.Stat(os.DevNull)
+	return err == nil && os.SameFile(info, null)
+}
+
+// domainOptions are per-domain settings from the inline directives of the
+// text input, overriding the global flags.
+type domainOptions struct {
+	warnDays int
+	// interval is the minimum time between two checks of the domain in the
+	// refresh loop, zero for the -refresh-interval.
+	interval time.Duration
+}
+
+// perDomain holds the options of the domains that have any, keyed by the
+// domain as written in the input.
+var perDomain = map[string]domainOptions{}
+
+// optionsFor returns the options of url, the global flags where the input
+// didn't override them.
+func optionsFor(url string) domainOptions {
+	if options, ok := perDomain[url]; ok {
+		return options
+	}
+
+	return domainOptions{warnDays: warnDays}
+}
+
+// parseInterval parses a positive duration, also accepting a number of days
+// with the d suffix.
+func parseInterval(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err == nil && d <= 0 {
+		err = fmt.Errorf("Non-positive interval %q", value)
+	}
+	return d, err
+}
+
+// readDomains reads one domain per line in the text format, or a JSON array
+// of strings or of objects with a "domain" key in the json format.
+//
+// In the text format a line may follow the domain with directives: warn=N
+// sets the expiration warning threshold in days, interval=D the minimum time
+// between two checks of the domain by -refresh-interval (a duration or a number
+// of days, such as 30d), #skip leaves the domain out.
+// Other tokens are ignored, as are lines starting with # or ;, a trailing dot
+// and repeated domains, so that dig output or a zone dump can be pasted.
+func readDomains(r io.Reader, format string) ([]string, error) {
+	switch format {
+	case "text":
+		var domains []string
+		seen := map[string]bool{}
+		scanner := bufio.NewScanner(r)
+
+		for n := 1; scanner.Scan(); n++ {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+				continue
+			}
+
+			domain := strings.TrimSuffix(fields[0], ".")
+			options, skip := optionsFor(domain), false
+			for _, directive := range fields[1:] {
+				switch {
+				case directive == "#skip":
+					skip = true
+				case strings.HasPrefix(directive, "warn="):
+					days, err := strconv.Atoi(strings.TrimPrefix(directive, "warn="))
+					if err != nil {
+						log.Printf("line %d: invalid directive %q", n, directive)
+						continue
+					}
+					options.warnDays = days
+					perDomain[domain] = options
+				case strings.HasPrefix(directive, "interval="):
+					interval, err := parseInterval(strings.TrimPrefix(directive, "interval="))
+					if err != nil {
+						log.Printf("line %d: invalid directive %q", n, directive)
+						continue
+					}
+					options.interval = interval
+					perDomain[domain] = options
+				case strings.Contains(directive, "=") || strings.HasPrefix(directive, "#"):
+					log.Printf("line %d: unknown directive %q", n, directive)
+				}
+			}
+
+			if !skip && !seen[domain] {
+				domains = append(domains, domain)
+			}
+			seen[domain] = true
+		}
+
+		return domains, scanner.Err()
+	case "json":
+		var items []json.RawMessage
+		if err := json.NewDecoder(r).Decode(&items); err != nil {
+			return nil, fmt.Errorf("Invalid JSON input: %v", err)
+		}
+
+		domains := make([]string, len(items))
+		for i, item := range items {
+			var object struct {
+				Domain string `json:"domain"`
+			}
+
+			if json.Unmarshal(item, &domains[i]) == nil {
+				continue
+			}
+
+			if err := json.Unmarshal(item, &object); err != nil || object.Domain == "" {
+				return nil, fmt.Errorf("Invalid JSON input: item %d is neither a string nor an object with a domain", i)
+			}
+			domains[i] = object.Domain
+		}
+
+		return domains, nil
+	default:
+		return nil, fmt.Errorf("Unknown input format %q", format)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// language holds the phrases of the report line in one language. The
+// relative-time functions get a positive number of days. The captcha prompt
+// is a template, {url} is replaced by the page to solve it at.
+type language struct {
+	captcha   string
+	free      string
+	dropped   string
+	reserved  string
+	today     string
+	expires   func(days int) string
+	expired   func(days int) string
+	dropsIn   func(days int) string
+	estimated string
+	thousands string
+	decimal   string
+}
+
+var languages = map[string]*language{
+	"en": {
+		captcha:  "Go to {url} and check the captcha.\nPress enter to continue.",
+		free:     "Free",
+		dropped:  "Free, registered until recently",
+		reserved: "Reserved, can't be registered",
+		today:    "Expires today",
+		expires:  func(days int) string { return "Expires in " + reportDay(days) },
+		expired:  func(days int) string { return "Expired " + reportDay(days) + " ago" },
+		dropsIn: func(days int) string {
+			if days == 0 {
+				return "becomes free today"
+			}
+			return "becomes free in " + reportDay(days)
+		},
+		estimated: " (estimated)",
+
+		thousands: ",",
+		decimal:   ".",
+	},
+	"cs": {
+		captcha:  "Otevřete {url} a vyplňte kontrolní kód.\nPak pokračujte klávesou Enter.",
+		free:     "Volná",
+		dropped:  "Volná, donedávna registrovaná",
+		reserved: "Rezervovaná, nelze registrovat",
+		today:    "Expiruje dnes",
+		expires:  func(days int) string { return "Expiruje za " + czechDays(days, "den", "dny", "dní") },
+		expired:  func(days int) string { return "Expirovala před " + czechDays(days, "dnem", "dny", "dny") },
+		dropsIn: func(days int) string {
+			if days == 0 {
+				return "uvolní se dnes"
+			}
+			return "uvolní se za " + czechDays(days, "den", "dny", "dní")
+		},
+		estimated: " (odhad)",
+
+		thousands: czechThousands,
+		decimal:   ",",
+	},
+}
+
+// czechThousands is a non-breaking space, which keeps the groups together.
+const czechThousands = "\u00a0"
+
+var lang = languages["en"]
+
+// groupDigits formats n with sep between the groups of thousands.
+func groupDigits(n int, sep string) string {
+	digits := strconv.Itoa(n)
+	sign := ""
+
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + sep + digits[i:]
+	}
+
+	return sign + digits
+}
+
+// number formats an integer for human output.
+func (l *language) number(n int) string {
+	return groupDigits(n, l.thousands)
+}
+
+// decimalNumber formats f with one decimal place for human output.
+func (l *language) decimalNumber(f float64) string {
+	tenths := int(f*10 + 0.5)
+	return fmt.Sprintf("%s%s%d", l.number(tenths/10), l.decimal, tenths%10)
+}
+
+func reportDay(expiration int) string {
+	if expiration < 0 {
+		expiration = -expiration
+	}
+
+	switch {
+	case expiration == 0:
+		return "today"
+	case expiration == 1:
+		return "1 day"
+	default:
+		return groupDigits(expiration, ",") + " days"
+	}
+}
+
+// czechDays declines the noun after the count: one, two to four, five and more.
+func czechDays(days int, one, few, many string) string {
+	switch {
+	case days == 1:
+		return fmt.Sprintf("%d %s", days, one)
+	case days >= 2 && days <= 4:
+		return fmt.Sprintf("%d %s", days, few)
+	default:
+		return fmt.Sprintf("%s %s", groupDigits(days, czechThousands), many)
+	}
+}
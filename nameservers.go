@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// nameserverSet normalizes a list of nameservers for an order- and
+// case-insensitive comparison.
+func nameserverSet(nameservers []string) []string {
+	var set []string
+	seen := map[string]bool{}
+
+	for _, ns := range nameservers {
+		ns = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(ns)), ".")
+		if ns != "" && !seen[ns] {
+			seen[ns] = true
+			set = append(set, ns)
+		}
+	}
+
+	sort.Strings(set)
+	return set
+}
+
+// nameserversMatch reports whether actual holds exactly the expected
+// nameservers. A domain without any nameservers never matches.
+func nameserversMatch(actual, expected []string) bool {
+	a, e := nameserverSet(actual), nameserverSet(expected)
+
+	if len(a) == 0 || len(a) != len(e) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != e[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nameserverMismatch tells whether -check-ns-match expects other nameservers
+// than the ones of result.
+func nameserverMismatch(result *CheckResult) bool {
+	return expectedNameservers != nil && !nameserversMatch(result.Nameservers, expectedNameservers)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// netrcLogin is the login and password of a machine of a netrc file.
+type netrcLogin struct {
+	login    string
+	password string
+}
+
+// readNetrc reads the logins of a netrc file by machine name, the default
+// entry under "". Macro definitions are skipped.
+func readNetrc(path string) (map[string]netrcLogin, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm()&0o077 != 0 {
+		log.Printf("Warning: %s is readable by other users", path)
+	}
+
+	logins := map[string]netrcLogin{}
+	machine, inEntry, inMacro := "", false, false
+
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if inMacro {
+			// A macro definition runs until a blank line.
+			inMacro = len(fields) > 0
+			continue
+		}
+		if len(fields) > 0 && fields[0] == "macdef" {
+			inMacro = true
+			continue
+		}
+
+		for i := 0; i < len(fields); i++ {
+			switch fields[i] {
+			case "machine":
+				if i+1 < len(fields) {
+					i++
+					machine, inEntry = fields[i], true
+					logins[machine] = netrcLogin{}
+				}
+			case "default":
+				machine, inEntry = "", true
+				logins[machine] = netrcLogin{}
+			case "login", "password", "account":
+				if i+1 >= len(fields) {
+					continue
+				}
+				i++
+				if !inEntry {
+					continue
+				}
+				entry := logins[machine]
+				if fields[i-1] == "login" {
+					entry.login = fields[i]
+				} else if fields[i-1] == "password" {
+					entry.password = fields[i]
+				}
+				logins[machine] = entry
+			}
+		}
+	}
+
+	return logins, nil
+}
+
+// proxyURL is the -proxy, nil to take the proxy from the environment.
+var proxyURL *url.URL
+
+// proxyLogins are the logins of -proxy-netrc, added to a proxy URL without
+// credentials so they never show on the command line or in the environment.
+var proxyLogins map[string]netrcLogin
+
+// proxyFor picks the proxy of a request, from -proxy or the environment, and
+// adds the login of its host from -proxy-netrc.
+func proxyFor(req *http.Request) (*url.URL, error) {
+	proxy := proxyURL
+	if proxy == nil {
+		var err error
+		if proxy, err = http.ProxyFromEnvironment(req); err != nil || proxy == nil {
+			return proxy, err
+		}
+	}
+
+	if proxy.User != nil || proxyLogins == nil {
+		return proxy, nil
+	}
+
+	entry, ok := proxyLogins[proxy.Hostname()]
+	if !ok {
+		entry, ok = proxyLogins[""]
+	}
+	if !ok || entry.login == "" {
+		return proxy, nil
+	}
+
+	withLogin := *proxy
+	withLogin.User = url.UserPassword(entry.login, entry.password)
+	return &withLogin, nil
+}
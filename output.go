@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DateFormat is the format of dates in the structured output.
+const DateFormat = "2006-01-02"
+
+// outputFormat selects how results are written: the human readable text,
+// jsonl with one JSON object per line, or at the end of the batch json with
+// an array of all of them or markdown with a table.
+var outputFormat = "text"
+
+// batchFormat tells whether the output format is written at the end of a
+// batch rather than line by line.
+func batchFormat() bool {
+	return outputFormat == "json" || outputFormat == "markdown"
+}
+
+// jsonPretty indents the array of the json format.
+var jsonPretty bool
+
+// reportResults is cleared by the modes that print something else than the
+// per-domain results.
+var reportResults = true
+
+// includeRawDate adds the unparsed expiration to the jsonl results.
+var includeRawDate bool
+
+// jsonResult is a result, or the error of a check, in the structured output.
+type jsonResult struct {
+	Domain        string   `json:"domain"`
+	Free          bool     `json:"free"`
+	Status        Status   `json:"status"`
+	Reserved      bool     `json:"reserved,omitempty"`
+	Expiration    string   `json:"expiration,omitempty"`
+	RawExpiration string   `json:"raw_expiration,omitempty"`
+	DropDate      string   `json:"drop_date,omitempty"`
+	DropEstimated bool     `json:"drop_date_estimated,omitempty"`
+	Registered    string   `json:"registered,omitempty"`
+	Nameservers   []string `json:"nameservers,omitempty"`
+	Keyset        string   `json:"keyset,omitempty"`
+	Registrar     string   `json:"registrar,omitempty"`
+	ContactEmail  string   `json:"contact_email,omitempty"`
+	Statuses      []string `json:"statuses,omitempty"`
+	Method        string   `json:"method,omitempty"`
+	FinalURL      string   `json:"final_url,omitempty"`
+	NSMismatch    bool     `json:"ns_mismatch,omitempty"`
+	Fields        []Field  `json:"fields,omitempty"`
+	Source        string   `json:"source,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// showDate appends the expiration date, in reportDateFormat, to the lines of
+// the text report.
+var showDate bool
+
+var reportDateFormat = DateFormat
+
+// reportZone makes the text report count the days left from the start of
+// today in the zone, nil to count from now.
+var reportZone *time.Location
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Format(DateFormat)
+}
+
+func toJSON(result *CheckResult) jsonResult {
+	r := jsonResult{
+		Domain:        result.URL,
+		Free:          result.IsFree,
+		Status:        result.Status,
+		Reserved:      result.Status == StatusReserved,
+		Expiration:    formatDate(result.Expiration),
+		DropDate:      formatDate(result.DropDate),
+		DropEstimated: result.DropDateEstimated,
+		Registered:    formatDate(result.Registered),
+		Nameservers:   result.Nameservers,
+		Keyset:        result.Keyset,
+		Registrar:     result.Registrar,
+		ContactEmail:  result.ContactEmail,
+		Statuses:      result.Statuses,
+		Method:        result.Method,
+		FinalURL:      result.FinalURL,
+		NSMismatch:    nameserverMismatch(result),
+		Fields:        result.Fields,
+	}
+
+	if includeRawDate {
+		r.RawExpiration = result.RawExpiration
+	}
+
+	return r
+}
+
+func report(w io.Writer, result *CheckResult) {
+	reportFrom(w, result, "")
+}
+
+// reportFrom reports result tagged with the input file it came from, if
+// there are several.
+func reportFrom(w io.Writer, result *CheckResult, source string) {
+	// A single write per line, so that with the unbuffered os.Stdout every
+	// result reaches a redirected file or pipe as soon as it's known.
+	if outputFormat == "jsonl" {
+		r := toJSON(result)
+		r.Source = source
+		writeJSONLine(w, structured(r))
+		return
+	}
+
+	line := result.String()
+	if fallback {
+		line += "\t" + result.Method
+	}
+	if source != "" {
+		line += "\t" + source
+	}
+	fmt.Fprintln(w, line)
+}
+
+func reportError(w io.Writer, url, source string, err error) {
+	if outputFormat == "jsonl" {
+		writeJSONLine(w, structured(jsonResult{Domain: url, Error: err.Error(), Source: source}))
+	}
+}
+
+// sources maps the domains read from several -f files to the files listing
+// them.
+var sources = map[string][]string{}
+
+// mergeSources reports a domain listed in several files once, tagged with all
+// of them, instead of once per file.
+var mergeSources = true
+
+// sourceTags returns the source tags to report the outcome of url with: one
+// line per tag, an untagged one if the domain didn't come from several files.
+func sourceTags(url string) []string {
+	files := sources[url]
+
+	switch {
+	case len(files) == 0:
+		return []string{""}
+	case mergeSources:
+		return []string{strings.Join(files, ",")}
+	default:
+		return files
+	}
+}
+
+func writeJSONLine(w io.Writer, v interface{}) {
+	line, _ := json.Marshal(v)
+	w.Write(append(line, '\n'))
+}
+
+// writeJSONArray writes the outcomes of a batch as the array of the json
+// format, failed checks being objects with an error.
+func writeJSONArray(w io.Writer, outcomes []outcome) {
+	results := []interface{}{}
+	for _, o := range outcomes {
+		for _, source := range sourceTags(o.url) {
+			results = append(results, structured(o.structured(source)))
+		}
+	}
+
+	var out []byte
+	if jsonPretty {
+		out, _ = json.MarshalIndent(results, "", "  ")
+	} else {
+		out, _ = json.Marshal(results)
+	}
+	w.Write(append(out, '\n'))
+}
+
+// writeMarkdownTable writes the outcomes of a batch as a GitHub-flavored
+// Markdown table, its columns padded to line up in plain text too. The
+// columns are the -output-fields if given.
+func writeMarkdownTable(w io.Writer, outcomes []outcome, now time.Time) {
+	rows := statusRows(outcomes, now)
+	if outputFields != nil {
+		rows = fieldRows(outcomes, now)
+	}
+
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			row[i] = strings.ReplaceAll(cell, "|", "\\|")
+			if n := utf8.RuneCountInString(row[i]); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	var table strings.Builder
+	writeRow := func(cells []string) {
+		for i, cell := range cells {
+			table.WriteString("| " + cell + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)) + " ")
+		}
+		table.WriteString("|\n")
+	}
+
+	writeRow(rows[0])
+	separator := make([]string, len(widths))
+	for i, width := range widths {
+		separator[i] = strings.Repeat("-", width)
+	}
+	writeRow(separator)
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+
+	io.WriteString(w, table.String())
+}
+
+// statusRows are the header and the rows of the default table: domain,
+// status, expiration and days left.
+func statusRows(outcomes []outcome, now time.Time) [][]string {
+	rows := [][]string{{"Domain", "Status", "Expiration", "Days left"}}
+
+	for _, o := range outcomes {
+		for _, source := range sourceTags(o.url) {
+			row := []string{o.url, "", "", ""}
+			switch {
+			case o.err != nil:
+				row[1] = "error: " + o.err.Error()
+			case o.result.Expiration.IsZero():
+				row[0], row[1] = o.result.URL, o.result.Status.String()
+			default:
+				row[0], row[1] = o.result.URL, o.result.Status.String()
+				row[2], row[3] = formatDate(o.result.Expiration), strconv.Itoa(o.result.daysLeft(now))
+			}
+			if source != "" {
+				row[0] += " (" + source + ")"
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return rows
+}
+
+// fieldRows are the header and the rows of the -output-fields table, with an
+// error column added when a check failed and error isn't one of the fields.
+func fieldRows(outcomes []outcome, now time.Time) [][]string {
+	header := append([]string{}, outputFields...)
+	var rows [][]string
+
+	for _, o := range outcomes {
+		for _, source := range sourceTags(o.url) {
+			object := selectFields(o.structured(source), now)
+			if len(object) > len(header) {
+				header = append(header, "error")
+			}
+
+			row := make([]string, len(object))
+			for i, field := range object {
+				row[i] = fieldText(field.value)
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	for i, row := range rows {
+		rows[i] = append(row, make([]string, len(header)-len(row))...)
+	}
+	return append([][]string{header}, rows...)
+}
+
+// outcome is the result or the error of checking one input.
+type outcome struct {
+	url     string
+	result  *CheckResult
+	err     error
+	elapsed time.Duration
+}
+
+// structured returns the outcome as a result of the structured output
+// tagged with source, failed checks being results with an error.
+func (o outcome) structured(source string) jsonResult {
+	r := jsonResult{Domain: o.url}
+	if o.err != nil {
+		r.Error = o.err.Error()
+	} else {
+		r = toJSON(o.result)
+	}
+	r.Source = source
+	return r
+}
+
+// collector gathers the outcomes of a batch for the reports printed at its
+// end. A nil collector discards them.
+type collector struct {
+	mu       sync.Mutex
+	outcomes []outcome
+}
+
+var collected *collector
+
+func (c *collector) add(o outcome) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.outcomes = append(c.outcomes, o)
+}
+
+// supersede replaces the outcome first with the one added last, a re-check
+// of the same domain, so the outcomes stay in the order they were checked in.
+func (c *collector) supersede(first outcome) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	last := len(c.outcomes) - 1
+	for i, o := range c.outcomes[:last] {
+		if o.url == first.url && o.err != nil && o.err.Error() == first.err.Error() {
+			c.outcomes[i] = c.outcomes[last]
+			c.outcomes = c.outcomes[:last]
+			return
+		}
+	}
+}
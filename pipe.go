@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// resultPipe is the -pipe-to subprocess, nil without one.
+var resultPipe *pipeCommand
+
+// pipeCommand is a subprocess fed the results of the whole run on its stdin,
+// one JSON object per line like -format jsonl, for whatever aggregation it
+// does. Its output goes to the output of the run.
+type pipeCommand struct {
+	command string
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+
+	mu     sync.Mutex
+	broken bool
+
+	// exitCode is the exit status of the finished subprocess, -1 if a signal
+	// ended it.
+	exitCode int
+}
+
+// startPipe runs command with the shell, reading what write sends it.
+func startPipe(command string) (*pipeCommand, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &pipeCommand{command: command, cmd: cmd, stdin: stdin}, nil
+}
+
+// write sends the outcome of a check to the subprocess, once per source tag
+// like the jsonl report. Once the subprocess stops reading, the rest of the
+// run is dropped with a single warning.
+func (p *pipeCommand) write(o outcome) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, source := range sourceTags(o.url) {
+		if p.broken {
+			return
+		}
+
+		line, _ := json.Marshal(structured(o.structured(source)))
+		if _, err := p.stdin.Write(append(line, '\n')); err != nil {
+			log.Printf("-pipe-to %q stopped reading the results: %v", p.command, err)
+			p.broken = true
+		}
+	}
+}
+
+// close ends the input of the subprocess and waits for it to finish. A
+// failed subprocess raises the exit code to ExitCheckFailed.
+func (p *pipeCommand) close() {
+	if p == nil {
+		return
+	}
+
+	p.stdin.Close()
+	err := p.cmd.Wait()
+	if p.cmd.ProcessState != nil {
+		p.exitCode = p.cmd.ProcessState.ExitCode()
+	}
+
+	if err != nil {
+		log.Printf("-pipe-to %q failed: %v", p.command, err)
+		setExitCode(ExitCheckFailed)
+	}
+}
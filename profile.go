@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// profiles are the presets of -profile: the values of the pacing flags each
+// sets. gentle halves the rate for a shared or sensitive network, fast keeps
+// the polite rate but overlaps the slow responses, normal is the defaults.
+var profiles = map[string]map[string]string{
+	"gentle": {"rate": "0.5", "concurrency": "1", "delay-on-error": "2s", "max-error-delay": "1m"},
+	"normal": {"rate": "1", "concurrency": "1", "delay-on-error": "1s", "max-error-delay": "30s"},
+	"fast":   {"rate": "1", "concurrency": "4", "delay-on-error": "1s", "max-error-delay": "30s"},
+}
+
+// profileNames lists the presets -profile accepts, sorted.
+func profileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyProfile sets the flags of the profile called name, except those set on
+// the command line, as listed in explicit. With -trace it logs the resolved
+// values.
+func applyProfile(name string, explicit map[string]bool) error {
+	settings, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("Unknown profile %q, -profile takes one of: %s", name, strings.Join(profileNames(), ", "))
+	}
+
+	var resolved []string
+	for flagName, value := range settings {
+		if !explicit[flagName] {
+			if err := flag.Set(flagName, value); err != nil {
+				return err
+			}
+		}
+		resolved = append(resolved, "-"+flagName+"="+flag.Lookup(flagName).Value.String())
+	}
+
+	if traceRequests {
+		sort.Strings(resolved)
+		log.Printf("trace profile %s: %s", name, strings.Join(resolved, " "))
+	}
+	return nil
+}
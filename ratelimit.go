@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces requests to the registry evenly, however many goroutines
+// make them. Reservations are handed out under a lock, so N concurrent callers
+// still get one slot per interval between them.
+//
+// Failures stretch the interval by step each, up to maxPenalty, and every
+// success shrinks it by step again, back to the base interval.
+type rateLimiter struct {
+	mu         sync.Mutex
+	interval   time.Duration
+	next       time.Time
+	penalty    time.Duration
+	step       time.Duration
+	maxPenalty time.Duration
+}
+
+func newRateLimiter(perSecond float64) *rateLimiter {
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait blocks until the caller may send its request, or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+
+	if l.next.Before(now) {
+		l.next = now
+	}
+
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval + l.penalty)
+	l.mu.Unlock()
+
+	return sleep(ctx, delay)
+}
+
+// failed slows the requests down after a failure.
+func (l *rateLimiter) failed() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.penalty += l.step; l.penalty > l.maxPenalty {
+		l.penalty = l.maxPenalty
+	}
+}
+
+// succeeded speeds the requests back up after a success.
+func (l *rateLimiter) succeeded() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.penalty -= l.step; l.penalty < 0 {
+		l.penalty = 0
+	}
+}
+
+// sleep pauses for d, returning early with the error of ctx once it's done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retryBudget counts the retries of the whole run and caps them at limit, if
+// set, so that a batch hitting trouble can't multiply its requests by
+// -retries. Once it's used up, failures are reported without retrying.
+type retryBudget struct {
+	mu     sync.Mutex
+	limit  int
+	used   int
+	warned bool
+}
+
+// runRetries is the retry budget of the run, see -retry-budget.
+var runRetries = &retryBudget{}
+
+// take spends a retry of the budget, false once there's none left.
+func (b *retryBudget) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit > 0 && b.used >= b.limit {
+		if !b.warned {
+			b.warned = true
+			log.Printf("The -retry-budget of %d retries is used up, not retrying any more", b.limit)
+		}
+		return false
+	}
+
+	b.used++
+	return true
+}
+
+// spent returns the number of retries taken so far.
+func (b *retryBudget) spent() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.used
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBoundsConcurrentCallers(t *testing.T) {
+	const (
+		rate       = 50.0
+		goroutines = 8
+		perWorker  = 5
+		calls      = goroutines * perWorker
+	)
+
+	l := newRateLimiter(rate)
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				if err := l.wait(context.Background()); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The first call goes at once, each of the others a slot later.
+	if elapsed, least := time.Since(start), time.Duration(float64(calls-1)/rate*float64(time.Second)); elapsed < least {
+		t.Errorf("%d calls at %v/s took %v, want at least %v", calls, rate, elapsed, least)
+	}
+}
+
+func TestRateLimiterGivesUpWithContext(t *testing.T) {
+	l := newRateLimiter(0.1)
+	l.wait(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := l.wait(ctx); err == nil {
+		t.Error("wait returned nil with the next slot 10 s away and the context done")
+	}
+}
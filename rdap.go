@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RDAPServer is the CZ.NIC RDAP service queried by the rdap method.
+const RDAPServer = "https://rdap.nic.cz"
+
+var rdapServer = RDAPServer
+
+// rdapDomain is the part of an RDAP domain response (RFC 9083) the rdap
+// method reads. A domain the registry doesn't know comes as an error
+// response with errorCode 404 instead.
+type rdapDomain struct {
+	ErrorCode   int          `json:"errorCode"`
+	Title       string       `json:"title"`
+	LDHName     string       `json:"ldhName"`
+	Status      []string     `json:"status"`
+	Events      []rdapEvent  `json:"events"`
+	Entities    []rdapEntity `json:"entities"`
+	Nameservers []struct {
+		LDHName string `json:"ldhName"`
+	} `json:"nameservers"`
+}
+
+type rdapEvent struct {
+	Action string `json:"eventAction"`
+	Date   string `json:"eventDate"`
+}
+
+// rdapEntity is a contact or the registrar, with its vCard as jCard (RFC
+// 7095): ["vcard", [[name, params, type, value], ...]].
+type rdapEntity struct {
+	Handle   string            `json:"handle"`
+	Roles    []string          `json:"roles"`
+	VCard    []json.RawMessage `json:"vcardArray"`
+	Entities []rdapEntity      `json:"entities"`
+}
+
+// hasRole tells whether the entity acts as role for the domain.
+func (e *rdapEntity) hasRole(role string) bool {
+	for _, r := range e.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// property returns the first value of the vCard property name, e.g. email.
+func (e *rdapEntity) property(name string) string {
+	if len(e.VCard) < 2 {
+		return ""
+	}
+
+	var properties [][]json.RawMessage
+	if json.Unmarshal(e.VCard[1], &properties) != nil {
+		return ""
+	}
+
+	for _, property := range properties {
+		var key, value string
+		if len(property) < 4 || json.Unmarshal(property[0], &key) != nil || key != name {
+			continue
+		}
+		if json.Unmarshal(property[3], &value) == nil {
+			return value
+		}
+	}
+	return ""
+}
+
+func getRDAPContent(ctx context.Context, domain string) (string, error) {
+	request, e := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(rdapServer, "/")+"/domain/"+domain, nil)
+
+	if e != nil {
+		return "", e
+	}
+
+	request.Header.Set("Accept", "application/rdap+json")
+
+	if traceRequests {
+		request = withTrace(request)
+	}
+
+	if e = limiter.wait(ctx); e != nil {
+		return "", e
+	}
+
+	response, e := clientFor(ctx).Do(request)
+
+	if e != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnreachable, e)
+	}
+
+	defer closeBody(response.Body)
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return `{"errorCode": 404}`, nil
+	case http.StatusServiceUnavailable:
+		return "", fmt.Errorf("%w: returned code %d", ErrServiceUnavailable, response.StatusCode)
+	case http.StatusTooManyRequests:
+		limiter.failed()
+		tuner.throttled()
+		return "", fmt.Errorf("%w: returned code %d", ErrRateLimited, response.StatusCode)
+	default:
+		return "", fmt.Errorf("%w: returned code %d", ErrUnreachable, response.StatusCode)
+	}
+
+	return readLimited(response.Body)
+}
+
+// checkRDAP checks a domain with the RDAP service, which answers in JSON and
+// never with a captcha.
+func checkRDAP(ctx context.Context, normalizedURL string) (*CheckResult, error) {
+	var content string
+	var err error
+
+	if fixturesDir != "" {
+		content, err = readFixture(normalizedURL, ".json")
+	} else {
+		content, err = getRDAPContent(ctx, normalizedURL)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return processRDAPResult(normalizedURL, content)
+}
+
+// processRDAPResult maps an RDAP domain response to a result: the dates from
+// its events, the registrar and contact email from its entities.
+func processRDAPResult(url, content string) (*CheckResult, error) {
+	var domain rdapDomain
+	if err := json.Unmarshal([]byte(content), &domain); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLayoutChanged, err)
+	}
+
+	ret := new(CheckResult)
+	ret.URL = url
+
+	switch domain.ErrorCode {
+	case 0:
+	case http.StatusNotFound:
+		explain(url, "free: the RDAP service has no record of the domain")
+		ret.Status = StatusFree
+		return ret, nil
+	default:
+		return nil, fmt.Errorf("%w: RDAP error %d %s", ErrUnreachable, domain.ErrorCode, domain.Title)
+	}
+
+	ret.Statuses = domain.Status
+
+	for _, event := range domain.Events {
+		date, err := rdapDate(event.Date)
+		if err != nil {
+			return nil, err
+		}
+
+		switch event.Action {
+		case "registration":
+			ret.Registered = date
+		case "expiration":
+			explain(url, "registered: expiration %q read from the %q event", event.Date, event.Action)
+			ret.Expiration = date
+			ret.RawExpiration = event.Date
+		case "deletion":
+			explain(url, "drop date %q read from the %q event", event.Date, event.Action)
+			ret.DropDate = date
+		}
+	}
+
+	var technical, administrative string
+	for i := range domain.Entities {
+		entity := &domain.Entities[i]
+		switch {
+		case entity.hasRole("registrar"):
+			ret.Registrar = entity.Handle
+		case entity.hasRole("technical") && technical == "":
+			technical = entity.property("email")
+		case entity.hasRole("administrative") && administrative == "":
+			administrative = entity.property("email")
+		}
+	}
+	if ret.ContactEmail = technical; ret.ContactEmail == "" {
+		ret.ContactEmail = administrative
+	}
+
+	for _, nameserver := range domain.Nameservers {
+		ret.Nameservers = append(ret.Nameservers, strings.ToLower(nameserver.LDHName))
+	}
+
+	var raw interface{}
+	json.Unmarshal([]byte(content), &raw)
+	ret.Fields = flattenJSON("", raw, nil)
+
+	if ret.Expiration.IsZero() {
+		return nil, fmt.Errorf("%w in the RDAP events", ErrMissingExpiration)
+	}
+
+	if err := checkPlausible(ret.Expiration, time.Now()); err != nil {
+		return nil, err
+	}
+
+	ret.Status = registrationStatus(ret.Expiration, time.Now(), ret.Statuses)
+
+	return ret, nil
+}
+
+// flattenJSON appends the leaves of value to fields, keyed by their path
+// below prefix: object keys sorted and array indexes joined with dots. A
+// null is left out.
+func flattenJSON(prefix string, value interface{}, fields []Field) []Field {
+	path := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fields = flattenJSON(path(key), value[key], fields)
+		}
+	case []interface{}:
+		for i, item := range value {
+			fields = flattenJSON(path(strconv.Itoa(i)), item, fields)
+		}
+	case string:
+		fields = append(fields, Field{prefix, value})
+	case nil:
+	default:
+		encoded, _ := json.Marshal(value)
+		fields = append(fields, Field{prefix, string(encoded)})
+	}
+
+	return fields
+}
+
+// rdapDate reads the day of an RDAP event date, as the registry wrote it
+// whatever its offset, at midnight UTC like strToDate.
+func rdapDate(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+)
+
+// recheckFailed holds the failed checks of a batch back and checks those
+// domains once more at its end, see -recheck-failed.
+var recheckFailed bool
+
+// held are the failed outcomes of the first pass awaiting the re-check.
+var held struct {
+	sync.Mutex
+	outcomes []outcome
+}
+
+// reportOrHold reports o, unless it's a failure -recheck-failed gets another
+// go at. Invalid input fails the same way every time, so it's reported.
+func reportOrHold(o outcome) {
+	if !recheckFailed || o.err == nil || errors.Is(o.err, ErrInvalidDomain) {
+		reportOutcome(o)
+		return
+	}
+
+	log.Printf("%s\t%s, re-checking at the end", o.url, o.err)
+	held.Lock()
+	held.outcomes = append(held.outcomes, o)
+	held.Unlock()
+}
+
+// recoveredDomains are the domains whose check failed in the first pass and
+// succeeded in the re-check.
+var recoveredDomains []string
+
+// recheckHeld checks the held domains once more, one at a time, and reports
+// their final outcomes in place of the first ones.
+func recheckHeld(ctx context.Context, stats *batchStats) {
+	held.Lock()
+	failed := held.outcomes
+	held.outcomes = nil
+	held.Unlock()
+
+	if len(failed) == 0 {
+		return
+	}
+
+	log.Printf("Re-checking %s failed domains", lang.number(len(failed)))
+	for _, first := range failed {
+		o := checkOutcome(ctx, first.url, stats)
+		collected.supersede(first)
+		if o.err == nil {
+			recoveredDomains = append(recoveredDomains, o.url)
+		}
+		reportOutcome(o)
+	}
+	log.Printf("Recovered %s of %s failed domains", lang.number(len(recoveredDomains)), lang.number(len(failed)))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// refreshEntry is the last known state of a domain in the refresh loop.
+type refreshEntry struct {
+	url      string
+	result   *CheckResult
+	checked  time.Time
+	interval time.Duration
+}
+
+func changed(old, new *CheckResult) bool {
+	return old == nil || old.IsFree != new.IsFree || !old.Expiration.Equal(new.Expiration)
+}
+
+// startRefreshLoop keeps the statuses of the domains fresh. After an initial
+// pass it wakes often enough to check every domain once per its interval (the
+// interval= directive, or interval) and re-checks the domain most overdue,
+// which spreads the checks evenly over time. Only results that changed are
+// reported.
+func startRefreshLoop(urls []string, interval time.Duration) {
+	entries := make([]*refreshEntry, len(urls))
+	for i, url := range urls {
+		entries[i] = &refreshEntry{url: url, interval: interval}
+		if options := optionsFor(url); options.interval > 0 {
+			entries[i].interval = options.interval
+		}
+	}
+
+	for _, entry := range entries {
+		refresh(entry)
+	}
+
+	var rate float64
+	for _, entry := range entries {
+		rate += 1 / entry.interval.Seconds()
+	}
+	wake := time.Duration(float64(time.Second) / rate)
+
+	for {
+		time.Sleep(wake)
+
+		var stalest *refreshEntry
+		for _, entry := range entries {
+			if entry.due().Before(time.Now()) && (stalest == nil || entry.due().Before(stalest.due())) {
+				stalest = entry
+			}
+		}
+
+		if stalest != nil {
+			refresh(stalest)
+		}
+	}
+}
+
+// due is when the domain should be checked again.
+func (entry *refreshEntry) due() time.Time {
+	return entry.checked.Add(entry.interval)
+}
+
+func refresh(entry *refreshEntry) {
+	start := time.Now()
+	result, err := CheckURL(entry.url)
+	entry.checked = time.Now()
+	o := outcome{url: entry.url, result: result, err: err, elapsed: entry.checked.Sub(start)}
+	history.checkRenewal(o, time.Now())
+	history.record(o)
+
+	if err != nil {
+		log.Printf("%s\t%s", entry.url, err)
+		return
+	}
+
+	if result.IsFree && entry.result != nil && (!entry.result.IsFree || entry.result.RecentlyDropped) {
+		result.RecentlyDropped = true
+	}
+
+	if changed(entry.result, result) {
+		report(os.Stdout, result)
+		resultPipe.write(o)
+	}
+	entry.result = result
+}
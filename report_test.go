@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the tests")
+
+// reportNow is the fixed current time of the report tests, a midnight UTC
+// like the expiration dates.
+var reportNow = time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+
+// reportCases are the results whose report lines the golden files lock.
+var reportCases = []*CheckResult{
+	{URL: "free.cz", IsFree: true, Status: StatusFree},
+	{URL: "today.cz", Expiration: reportNow, Status: StatusRegistered},
+	{URL: "expired.cz", Expiration: reportNow.AddDate(0, 0, -2), Status: StatusExpired},
+	{URL: "soon.cz", Expiration: reportNow.AddDate(0, 0, 5), Status: StatusRegistered},
+	{URL: "dropping.cz", Expiration: reportNow.AddDate(0, 0, -40), Status: StatusProtected,
+		DropDate: reportNow.AddDate(0, 0, 21), DropDateEstimated: true},
+	{URL: "deleted.cz", Expiration: reportNow.AddDate(0, 0, -40), Status: StatusProtected,
+		DropDate: reportNow.AddDate(0, 0, 3)},
+}
+
+// golden compares got with testdata/name, or rewrites the file with -update.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs:\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestReportGolden(t *testing.T) {
+	for _, name := range []string{"en", "cs"} {
+		t.Run(name, func(t *testing.T) {
+			var b strings.Builder
+			for _, r := range reportCases {
+				b.WriteString(r.format(reportNow, languages[name]) + "\n")
+			}
+			golden(t, "report_"+name+".golden", b.String())
+		})
+	}
+}
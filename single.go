@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// selectors extract the fields of -select as plain text, empty when the
+// result doesn't have the field.
+var selectors = map[string]func(r *CheckResult) string{
+	"expiration":     func(r *CheckResult) string { return formatDate(r.Expiration) },
+	"drop_date":      func(r *CheckResult) string { return formatDate(r.DropDate) },
+	"created":        func(r *CheckResult) string { return formatDate(r.Registered) },
+	"registrar":      func(r *CheckResult) string { return r.Registrar },
+	"nameservers":    func(r *CheckResult) string { return strings.Join(r.Nameservers, ",") },
+	"contact_email":  func(r *CheckResult) string { return r.ContactEmail },
+	"keyset":         func(r *CheckResult) string { return r.Keyset },
+	"statuses":       func(r *CheckResult) string { return strings.Join(r.Statuses, ",") },
+	"free":           func(r *CheckResult) string { return strconv.FormatBool(r.IsFree) },
+	"reserved":       func(r *CheckResult) string { return strconv.FormatBool(r.Status == StatusReserved) },
+	"status":         func(r *CheckResult) string { return r.Status.String() },
+	"method":         func(r *CheckResult) string { return r.Method },
+	"raw_expiration": func(r *CheckResult) string { return r.RawExpiration },
+	"final_url":      func(r *CheckResult) string { return r.FinalURL },
+}
+
+// selectorNames lists the fields -select accepts, sorted.
+func selectorNames() []string {
+	names := make([]string, 0, len(selectors))
+	for name := range selectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// printSelected checks urls and prints only field of each, for capturing in
+// a shell variable; multi-valued fields are comma-separated. With more than
+// one domain each value is prefixed by the domain and a tab. It returns the
+// exit code: non-zero with a message on stderr when a domain lacks the field
+// (e.g. the expiration of a free one) or the check failed.
+func printSelected(urls []string, field string) int {
+	selector := selectors[field]
+	code := ExitOK
+
+	for _, url := range urls {
+		result, err := CheckURL(url)
+
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", url, err)
+			if c := exitCodeFor(err); c > code {
+				code = c
+			}
+			continue
+		}
+
+		value := selector(result)
+
+		switch {
+		case value != "" && len(urls) > 1:
+			fmt.Printf("%s\t%s\n", result.URL, value)
+		case value != "":
+			fmt.Println(value)
+		case result.IsFree:
+			fmt.Fprintf(os.Stderr, "%s is free\n", result.URL)
+		default:
+			fmt.Fprintf(os.Stderr, "%s has no %s\n", result.URL, field)
+		}
+
+		if value == "" && code < ExitCheckFailed {
+			code = ExitCheckFailed
+		}
+	}
+
+	return code
+}
+
+// assertions are the states -assert accepts, each with the statuses that
+// satisfy it. A taken domain can't be registered now, an expired one waits
+// for renewal or deletion.
+var assertions = map[string][]Status{
+	"free":    {StatusFree},
+	"taken":   {StatusRegistered, StatusReserved},
+	"expired": {StatusExpired, StatusProtected},
+}
+
+// assertionNames lists the states -assert accepts, sorted.
+func assertionNames() []string {
+	names := make([]string, 0, len(assertions))
+	for name := range assertions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// assertStatus checks url and returns ExitOK when its status is the asserted
+// one, ExitMismatch with a message on stderr when it isn't, or the exit code
+// of the error when the check failed.
+func assertStatus(url, want string) int {
+	result, err := CheckURL(url)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", url, err)
+		return exitCodeFor(err)
+	}
+
+	for _, status := range assertions[want] {
+		if result.Status == status {
+			return ExitOK
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "%s is %s, not %s\n", result.URL, result.Status, want)
+	return ExitMismatch
+}
+
+// singleExitCode checks url, reports it like a batch would and returns
+// ExitSingleFree if it's free, ExitSingleTaken if it's in any other status and
+// ExitSingleFailed if the check failed, for -single-exit-codes.
+func singleExitCode(url string) int {
+	result, err := CheckURL(url)
+	if err != nil {
+		log.Printf("%s\t%s", url, err)
+		reportError(os.Stdout, url, "", err)
+		return ExitSingleFailed
+	}
+
+	report(os.Stdout, result)
+	if result.IsFree {
+		return ExitSingleFree
+	}
+	return ExitSingleTaken
+}
+
+// firstDomains returns the first k of urls, counting inputs that normalize to
+// the same domain once. Inputs that don't normalize count as they are, to
+// fail when checked.
+func firstDomains(urls []string, k int) []string {
+	var first []string
+	seen := map[string]bool{}
+
+	for _, url := range urls {
+		if len(first) == k {
+			break
+		}
+
+		key := url
+		if domain, err := normalizeCzURL(url); err == nil {
+			key = domain
+		}
+		if !seen[key] {
+			seen[key] = true
+			first = append(first, url)
+		}
+	}
+
+	return first
+}
+
+// printNormalized writes each of urls with the domain it would check, or why
+// it can't be checked, for -dry-run. It returns ExitInvalidDomain if any
+// input doesn't normalize.
+func printNormalized(w io.Writer, urls []string) int {
+	code := ExitOK
+
+	for _, url := range urls {
+		domain, err := normalizeCzURL(url)
+		if err != nil {
+			code = ExitInvalidDomain
+		}
+
+		switch {
+		case outputFormat != "text" && err != nil:
+			writeJSONLine(w, map[string]string{"input": url, "error": err.Error()})
+		case outputFormat != "text":
+			writeJSONLine(w, map[string]string{"input": url, "domain": domain})
+		case err != nil:
+			fmt.Fprintf(w, "%s\t%s\n", url, err)
+		default:
+			fmt.Fprintf(w, "%s\t%s\n", url, domain)
+		}
+	}
+
+	return code
+}
+
+// printNormalizedOnly writes the domain each of urls normalizes to, one per
+// line, for -normalize-only. The inputs that don't normalize are logged to
+// stderr instead, and make it return ExitInvalidDomain.
+func printNormalizedOnly(w io.Writer, urls []string) int {
+	code := ExitOK
+
+	for _, url := range urls {
+		domain, err := normalizeCzURL(url)
+		if err != nil {
+			log.Printf("%s\t%s", url, err)
+			code = ExitInvalidDomain
+			continue
+		}
+		fmt.Fprintln(w, domain)
+	}
+
+	return code
+}
+
+// validationReport is the pre-flight summary of -validate-only.
+type validationReport struct {
+	Valid      int             `json:"valid"`
+	Rejected   []rejectedInput `json:"rejected"`
+	Duplicates int             `json:"duplicates"`
+}
+
+// rejectedInput is an input that doesn't normalize to a checkable domain.
+type rejectedInput struct {
+	Input string `json:"input"`
+	Error string `json:"error"`
+}
+
+// validateInput normalizes all of urls like the checks would and writes how
+// many are valid, which were rejected and why, and how many normalize to a
+// domain already in the list, for -validate-only. It returns
+// ExitInvalidDomain if any was rejected.
+func validateInput(w io.Writer, urls []string) int {
+	summary := validationReport{Rejected: []rejectedInput{}}
+	seen := map[string]bool{}
+
+	for _, url := range urls {
+		domain, err := normalizeCzURL(url)
+		switch {
+		case err != nil:
+			summary.Rejected = append(summary.Rejected, rejectedInput{url, err.Error()})
+		case seen[domain]:
+			summary.Duplicates++
+		default:
+			seen[domain] = true
+			summary.Valid++
+		}
+	}
+
+	if outputFormat != "text" {
+		writeJSONLine(w, summary)
+	} else {
+		fmt.Fprintf(w, "%s valid domains, %s rejected, %s duplicates removed\n",
+			lang.number(summary.Valid), lang.number(len(summary.Rejected)), lang.number(summary.Duplicates))
+		for _, rejected := range summary.Rejected {
+			fmt.Fprintf(w, "%s\t%s\n", rejected.Input, rejected.Error)
+		}
+	}
+
+	if len(summary.Rejected) > 0 {
+		return ExitInvalidDomain
+	}
+	return ExitOK
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SMTPPasswordEnv names the environment variable holding the password of
+// -smtp-user, kept out of the command line.
+const SMTPPasswordEnv = "CZDOMAIN_SMTP_PASSWORD"
+
+// SMTPWarnDays is the threshold of the expiring domains in the report when
+// -warn-days isn't set.
+const SMTPWarnDays = 30
+
+// MaxReportLogLines caps the warnings copied into the report.
+const MaxReportLogLines = 200
+
+// mailReport is the -smtp-host report of a run.
+type mailReport struct {
+	host string
+	from string
+	to   []string
+	user string
+	when string
+
+	mu   sync.Mutex
+	logs []string
+}
+
+// Write keeps the lines logged during the run for the report, so it can
+// include the warnings, while they still go to stderr.
+func (m *mailReport) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.logs) < MaxReportLogLines {
+		m.logs = append(m.logs, strings.TrimRight(string(p), "\n"))
+	}
+	return os.Stderr.Write(p)
+}
+
+// noteworthy tells whether the run is worth a mail: with -smtp-when always
+// every run is, otherwise one with a failed or mismatched check, or a domain
+// expired or expiring within days.
+func (m *mailReport) noteworthy(outcomes []outcome, days, code int) bool {
+	if m.when == "always" || code != ExitOK {
+		return true
+	}
+	return len(expiringDomains(outcomes, days)) > 0
+}
+
+// expiringDomains returns the results of outcomes that expired or expire
+// within days.
+func expiringDomains(outcomes []outcome, days int) []*CheckResult {
+	var expiring []*CheckResult
+	for _, o := range outcomes {
+		if o.err == nil && o.result.IsExpiringSoon(time.Duration(days)*24*time.Hour) {
+			expiring = append(expiring, o.result)
+		}
+	}
+	return expiring
+}
+
+// send mails the report of the outcomes if the run is noteworthy. A failure
+// is logged, it doesn't change the exit code of the run.
+func (m *mailReport) send(outcomes []outcome, elapsed time.Duration, code int) {
+	days := warnDays
+	if days <= 0 {
+		days = SMTPWarnDays
+	}
+
+	if !m.noteworthy(outcomes, days, code) {
+		return
+	}
+
+	var body bytes.Buffer
+	writeSummary(&body, outcomes, elapsed, code)
+
+	if expiring := expiringDomains(outcomes, days); len(expiring) > 0 {
+		fmt.Fprintf(&body, "\nExpired or expiring within %d days:\n", days)
+		for _, result := range expiring {
+			fmt.Fprintf(&body, "  %s (%s)\n", result.String(), formatDate(result.Expiration))
+		}
+	}
+
+	var failed []string
+	for _, o := range outcomes {
+		if o.err != nil {
+			failed = append(failed, fmt.Sprintf("  %s\t%s", o.url, o.err))
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Fprintf(&body, "\nFailed:\n%s\n", strings.Join(failed, "\n"))
+	}
+
+	m.mu.Lock()
+	if len(m.logs) > 0 {
+		fmt.Fprintf(&body, "\nWarnings:\n  %s\n", strings.Join(m.logs, "\n  "))
+	}
+	m.mu.Unlock()
+
+	subject := fmt.Sprintf("czdomain: %d domains checked, exit code %d", len(outcomes), code)
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		m.from, strings.Join(m.to, ", "), subject, time.Now().Format(time.RFC1123Z),
+		strings.ReplaceAll(body.String(), "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if m.user != "" {
+		host, _, _ := net.SplitHostPort(m.host)
+		auth = smtp.PlainAuth("", m.user, os.Getenv(SMTPPasswordEnv), host)
+	}
+
+	if err := smtp.SendMail(m.host, auth, m.from, m.to, []byte(message)); err != nil {
+		log.Printf("Can't send the report: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Status is the registration state of a domain.
+type Status int
+
+const (
+	// StatusUnknown is the zero value, for results that don't say.
+	StatusUnknown Status = iota
+
+	// StatusFree means the domain can be registered.
+	StatusFree
+
+	// StatusRegistered means the domain is held and not expired.
+	StatusRegistered
+
+	// StatusExpired means the expiration passed but the domain is still in
+	// the zone; the holder can renew it.
+	StatusExpired
+
+	// StatusProtected means an expired domain was taken out of the zone and
+	// waits for deletion. Only the holder can still renew it.
+	StatusProtected
+
+	// StatusReserved means the registry reserved or blocked the name.
+	StatusReserved
+)
+
+var statusNames = map[Status]string{
+	StatusUnknown:    "unknown",
+	StatusFree:       "free",
+	StatusRegistered: "registered",
+	StatusExpired:    "expired",
+	StatusProtected:  "protected",
+	StatusReserved:   "reserved",
+}
+
+// ProtectedMarkers are the status descriptions, of the web page, of port 43
+// and of RDAP, of a domain taken out of the zone after its expiration.
+var ProtectedMarkers = []string{
+	"není generována do zóny",
+	"určeno ke zrušení",
+	"not generated into zone",
+	"to be deleted",
+	"inactive",
+	"pending delete",
+}
+
+func (s Status) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// MarshalJSON writes the status as its snake_case name.
+func (s Status) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON reads a status written by MarshalJSON.
+func (s *Status) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+
+	for status, n := range statusNames {
+		if n == name {
+			*s = status
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Unknown status %q", name)
+}
+
+// registrationStatus tells a registered domain from an expired or protected
+// one by its expiration and status descriptions.
+func registrationStatus(expiration, now time.Time, statuses []string) Status {
+	if !expiration.Before(now) {
+		return StatusRegistered
+	}
+
+	for _, status := range statuses {
+		for _, marker := range ProtectedMarkers {
+			if strings.Contains(strings.ToLower(status), marker) {
+				return StatusProtected
+			}
+		}
+	}
+
+	return StatusExpired
+}
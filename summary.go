@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// SummarySchemaVersion is raised whenever a field of the -summary-json
+// object changes meaning or goes away; new fields don't raise it.
+const SummarySchemaVersion = 1
+
+type summaryError struct {
+	Domain string `json:"domain"`
+	Error  string `json:"error"`
+}
+
+type runSummary struct {
+	SchemaVersion int            `json:"schema_version"`
+	Checked       int            `json:"checked"`
+	Free          int            `json:"free"`
+	Registered    int            `json:"registered"`
+	Reserved      int            `json:"reserved"`
+	Failed        int            `json:"failed"`
+	TimedOut      int            `json:"timed_out"`
+	Requests      int64          `json:"requests"`
+	Retries       int            `json:"retries"`
+	RetryBudget   int            `json:"retry_budget,omitempty"`
+	DurationMs    int64          `json:"duration_ms"`
+	ExitCode      int            `json:"exit_code"`
+	PipeExitCode  *int           `json:"pipe_exit_code,omitempty"`
+	Errors        []summaryError `json:"errors"`
+	Recovered     []string       `json:"recovered,omitempty"`
+}
+
+// writeSummary writes the -summary-json object for the outcomes of a run to w
+// as a single line.
+func writeSummary(w io.Writer, outcomes []outcome, elapsed time.Duration, code int) {
+	summary := runSummary{
+		SchemaVersion: SummarySchemaVersion,
+		Checked:       len(outcomes),
+		Requests:      registryRequests.Load(),
+		Retries:       runRetries.spent(),
+		RetryBudget:   runRetries.limit,
+		DurationMs:    elapsed.Milliseconds(),
+		ExitCode:      code,
+		Errors:        []summaryError{},
+		Recovered:     recoveredDomains,
+	}
+	if resultPipe != nil {
+		summary.PipeExitCode = &resultPipe.exitCode
+	}
+
+	for _, o := range outcomes {
+		switch {
+		case o.err != nil:
+			summary.Failed++
+			if errors.Is(o.err, ErrTimedOut) {
+				summary.TimedOut++
+			}
+			summary.Errors = append(summary.Errors, summaryError{Domain: o.url, Error: o.err.Error()})
+		case o.result.Status == StatusReserved:
+			summary.Reserved++
+		case o.result.IsFree:
+			summary.Free++
+		default:
+			summary.Registered++
+		}
+	}
+
+	writeJSONLine(w, summary)
+}
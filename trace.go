@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/tls"
+	"log"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// traceRequests makes every WHOIS request log its timing to stderr.
+var traceRequests bool
+
+// withTrace attaches a trace to req that logs the DNS lookup, connection,
+// TLS handshake and time to first byte once the response starts arriving.
+// Steps skipped thanks to a reused connection are reported as zero.
+func withTrace(req *http.Request) *http.Request {
+	var start, dnsStart, connectStart, tlsStart time.Time
+	var dns, connect, handshake time.Duration
+	reused := false
+
+	trace := &httptrace.ClientTrace{
+		GetConn:  func(string) { start = time.Now() },
+		GotConn:  func(info httptrace.GotConnInfo) { reused = info.Reused },
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:  func(httptrace.DNSDoneInfo) { dns = time.Since(dnsStart) },
+		ConnectStart: func(string, string) {
+			connectStart = time.Now()
+		},
+		ConnectDone: func(string, string, error) {
+			connect = time.Since(connectStart)
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			handshake = time.Since(tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			connection := "new connection"
+			if reused {
+				connection = "reused connection"
+			}
+			log.Printf("trace %s: %s, dns %s, connect %s, tls %s, first byte %s", req.URL, connection,
+				dns.Round(time.Millisecond), connect.Round(time.Millisecond),
+				handshake.Round(time.Millisecond), time.Since(start).Round(time.Millisecond))
+		},
+	}
+
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
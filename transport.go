@@ -0,0 +1,86 @@
+package main
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ConnectTimeout is the default of -connect-timeout, the limit on
+// establishing a connection including the TLS handshake.
+const ConnectTimeout = 10 * time.Second
+
+// Timeout is the default of -timeout, the limit on a whole request.
+const Timeout = 30 * time.Second
+
+var (
+	connectTimeout = ConnectTimeout
+	requestTimeout = Timeout
+)
+
+// http1Only keeps the http client to HTTP/1.1, for networks whose middleboxes
+// stall HTTP/2, see -http1.
+var http1Only bool
+
+// IdleConnsPerHost is how many idle connections to the registry are kept for
+// reuse, enough for every worker of a concurrent batch.
+const IdleConnsPerHost = 16
+
+// IdleConnTimeout is how long an idle connection is kept. With the rate
+// limit spacing the requests by a second or so, they keep reusing it.
+const IdleConnTimeout = 90 * time.Second
+
+// DrainLimit is how much of an unread body is read off before closing it, so
+// the connection can be reused. Anything longer isn't worth the wait.
+const DrainLimit = 64 << 10
+
+func newDialer() *net.Dialer {
+	return &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+}
+
+// newHTTPClient builds the client of the http method from the flags.
+func newHTTPClient() *http.Client {
+	transport := &http.Transport{
+		Proxy:               proxyFor,
+		DialContext:         newDialer().DialContext,
+		TLSHandshakeTimeout: connectTimeout,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        IdleConnsPerHost,
+		MaxIdleConnsPerHost: IdleConnsPerHost,
+		IdleConnTimeout:     IdleConnTimeout,
+	}
+
+	if http1Only {
+		// A non-nil empty TLSNextProto is what turns HTTP/2 off, the ALPN
+		// list only keeps the server from offering it.
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSClientConfig = &tls.Config{NextProtos: []string{"http/1.1"}}
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return &http.Client{Transport: countingTransport{transport}, Timeout: requestTimeout}
+}
+
+// closeBody drains what's left of body before closing it. A connection is
+// only reused once its response was read to the end.
+func closeBody(body io.ReadCloser) {
+	io.CopyN(io.Discard, body, DrainLimit)
+	body.Close()
+}
+
+// registryRequests counts the queries sent to the registry, retries, captcha
+// re-fetches and redirects included.
+var registryRequests atomic.Int64
+
+// countingTransport counts every request it sends in registryRequests.
+type countingTransport struct {
+	http.RoundTripper
+}
+
+func (t countingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	registryRequests.Add(1)
+	return t.RoundTripper.RoundTrip(request)
+}
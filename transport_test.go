@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// countConnections serves the page of twice.cz, or a 503 to down.cz, until
+// the end of the test, and counts the connections opened to it. Each request
+// first waits for hold, if set.
+func countConnections(t *testing.T, hold func()) *atomic.Int64 {
+	page := readTestdata(t, "twice.cz.html")
+	var opened atomic.Int64
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hold != nil {
+			hold()
+		}
+		if r.URL.Path == "/whois/domain/down.cz" {
+			http.Error(w, "Service Unavailable, please try again later", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(page))
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			opened.Add(1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+
+	override(t, &baseURL, server.URL)
+	override(t, &limiter, newRateLimiter(1000))
+	override(t, &httpClient, newHTTPClient())
+
+	return &opened
+}
+
+func TestSequentialQueriesReuseConnection(t *testing.T) {
+	opened := countConnections(t, nil)
+
+	for i := 0; i < 10; i++ {
+		domain := "twice.cz"
+		if i%3 == 1 {
+			// The body of a failed query is drained, keeping the connection.
+			domain = "down.cz"
+		}
+		getPageContent(context.Background(), queryURL(domain))
+	}
+
+	if n := opened.Load(); n != 1 {
+		t.Errorf("10 sequential queries opened %d connections, want 1", n)
+	}
+}
+
+func TestConcurrentQueriesKeepIdleConnections(t *testing.T) {
+	const workers, queries = 4, 5
+
+	// The requests of a round are answered once all of them arrived, so
+	// each round has all the workers' connections busy at once.
+	var round atomic.Pointer[sync.WaitGroup]
+	opened := countConnections(t, func() {
+		arrived := round.Load()
+		arrived.Done()
+		arrived.Wait()
+	})
+
+	var wg sync.WaitGroup
+	for r := 0; r < queries; r++ {
+		arrived := new(sync.WaitGroup)
+		arrived.Add(workers)
+		round.Store(arrived)
+
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, _, err := getPageContent(context.Background(), queryURL("twice.cz")); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	if n := opened.Load(); n > workers {
+		t.Errorf("%d workers making %d queries each opened %d connections, want at most %d", workers, queries, n, workers)
+	}
+}
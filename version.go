@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+)
+
+// Version is the release of the tool, set with -ldflags "-X main.Version=...".
+var Version = "dev"
+
+// LayoutRevision identifies the nic.cz page layout the http parser was last
+// checked against. Bump it whenever the haystacks or offsets change.
+const LayoutRevision = "2026-10"
+
+// LayoutWarningThreshold is how many pages with an unknown layout trigger the
+// suggestion to update the tool.
+const LayoutWarningThreshold = 3
+
+// layoutFailures counts the pages in a row the http parser didn't recognize.
+var layoutFailures struct {
+	sync.Mutex
+	count  int
+	warned bool
+}
+
+// noteLayoutError counts err if it's ErrLayoutChanged and, once that happened
+// repeatedly, suggests a newer version of the tool. One odd page is likely an
+// error page, several in a row mean nic.cz changed its layout; a parsed page
+// starts the count over.
+func noteLayoutError(err error) {
+	layoutFailures.Lock()
+	defer layoutFailures.Unlock()
+
+	if err == nil {
+		layoutFailures.count = 0
+	}
+
+	if !errors.Is(err, ErrLayoutChanged) {
+		return
+	}
+
+	layoutFailures.count++
+	if layoutFailures.count < LayoutWarningThreshold || layoutFailures.warned {
+		return
+	}
+
+	layoutFailures.warned = true
+	log.Printf("*** %d pages didn't match the nic.cz layout this version (%s, layout %s) knows. "+
+		"The registry likely changed its pages: update czdomain, or use -method whois43 (or -fallback) meanwhile. ***",
+		layoutFailures.count, Version, LayoutRevision)
+}
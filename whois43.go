@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WhoisServer is the nic.cz WHOIS service queried by the whois43 method.
+const WhoisServer = "whois.nic.cz:43"
+
+// WhoisFree means the WHOIS service has no record of the domain.
+const WhoisFree = "no entries found"
+
+var whoisServer = WhoisServer
+
+func getWhoisContent(ctx context.Context, domain string) (string, error) {
+	if e := limiter.wait(ctx); e != nil {
+		return "", e
+	}
+
+	registryRequests.Add(1)
+	conn, e := newDialer().DialContext(ctx, "tcp", whoisServer)
+
+	if e != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnreachable, e)
+	}
+
+	defer conn.Close()
+
+	deadline := time.Now().Add(requestTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	if _, e = fmt.Fprintf(conn, "%s\r\n", domain); e != nil {
+		return "", fmt.Errorf("%w: %v", ErrUnreachable, e)
+	}
+
+	return readLimited(conn)
+}
+
+func checkWhois43(ctx context.Context, normalizedURL string) (*CheckResult, error) {
+	var content string
+	var err error
+
+	if fixturesDir != "" {
+		content, err = readFixture(normalizedURL, ".txt")
+	} else {
+		content, err = getWhoisContent(ctx, normalizedURL)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return processWhoisResult(normalizedURL, content)
+}
+
+// whoisBlock is one paragraph of a WHOIS response, e.g. the domain, a
+// contact or the nsset. Keys may repeat (status, nserver, address) and keep
+// all their values in order; fields has the lines in their order.
+type whoisBlock struct {
+	values map[string][]string
+	fields []Field
+}
+
+// first returns the first value of key, empty if the block doesn't have it.
+func (b *whoisBlock) first(key string) string {
+	if values := b.values[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// parseWhoisBlocks splits a WHOIS response into its blank line separated
+// blocks of "key: value" lines. An indented line continues the value on the
+// line above, so a value wrapped over several
+// lines is joined with single spaces. Lines starting with % are comments.
+func parseWhoisBlocks(content string) []*whoisBlock {
+	var blocks []*whoisBlock
+	var block *whoisBlock
+	var lastKey string
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+
+		if line == "" || strings.HasPrefix(line, "%") {
+			block = nil
+			continue
+		}
+
+		if block != nil && (raw[0] == ' ' || raw[0] == '\t') {
+			values := block.values[lastKey]
+			values[len(values)-1] += " " + line
+			block.fields[len(block.fields)-1].Value = values[len(values)-1]
+			continue
+		}
+
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			continue
+		}
+
+		if block == nil {
+			block = &whoisBlock{values: map[string][]string{}}
+			blocks = append(blocks, block)
+		}
+
+		lastKey = line[:colon]
+		value := strings.TrimSpace(line[colon+1:])
+		block.values[lastKey] = append(block.values[lastKey], value)
+		block.fields = append(block.fields, Field{lastKey, value})
+	}
+
+	return blocks
+}
+
+// contactEmail returns the e-mail of the first of handles whose contact block
+// discloses one, or an empty string. The registry hides undisclosed e-mails
+// entirely, so there's nothing to guess from.
+func contactEmail(blocks []*whoisBlock, handles []string) string {
+	for _, handle := range handles {
+		for _, block := range blocks {
+			if block.first("contact") == handle && block.first("e-mail") != "" {
+				return block.first("e-mail")
+			}
+		}
+	}
+	return ""
+}
+
+// processWhoisResult parses the "key: value" response of the WHOIS service.
+// Only the domain block is used for the domain's own attributes, the nsset
+// block it refers to supplies the nameservers.
+func processWhoisResult(url, content string) (*CheckResult, error) {
+	ret := new(CheckResult)
+	ret.URL = url
+
+	if strings.Contains(content, WhoisFree) {
+		explain(url, "free: %q found in the WHOIS response", WhoisFree)
+		ret.Status = StatusFree
+		return ret, nil
+	}
+
+	blocks := parseWhoisBlocks(content)
+	domain := &whoisBlock{}
+
+	for _, block := range blocks {
+		if _, ok := block.values["domain"]; ok {
+			domain = block
+			break
+		}
+	}
+
+	ret.Keyset = domain.first("keyset")
+	ret.Registrar = domain.first("registrar")
+	ret.Statuses = domain.values["status"]
+
+	if value := domain.first("registered"); len(value) >= ExpirationLength {
+		registered, err := strToDate(value[:ExpirationLength])
+		if err != nil {
+			return nil, err
+		}
+		ret.Registered = registered
+	}
+
+	if value := domain.first("expire"); len(value) >= ExpirationLength {
+		explain(url, "registered: expiration %q read from the %q line", value[:ExpirationLength], "expire")
+		expiration, err := strToDate(value[:ExpirationLength])
+		if err != nil {
+			return nil, err
+		}
+		ret.Expiration = expiration
+		ret.RawExpiration = value
+	}
+
+	// Responses without the nsset handle in the domain block have a single
+	// nsset block, if any.
+	nsset := domain.first("nsset")
+	var techContacts []string
+	for _, block := range blocks {
+		if _, ok := block.values["nserver"]; !ok || (nsset != "" && block.first("nsset") != nsset) {
+			continue
+		}
+
+		techContacts = append(techContacts, block.values["tech-c"]...)
+
+		for _, value := range block.values["nserver"] {
+			if fields := strings.Fields(value); len(fields) > 0 {
+				ret.Nameservers = append(ret.Nameservers, fields[0])
+			}
+		}
+	}
+
+	ret.ContactEmail = contactEmail(blocks, append(techContacts, domain.values["admin-c"]...))
+
+	for _, block := range blocks {
+		ret.Fields = append(ret.Fields, block.fields...)
+	}
+
+	if ret.Expiration.IsZero() {
+		return nil, fmt.Errorf("%w in the WHOIS response", ErrMissingExpiration)
+	}
+
+	if err := checkPlausible(ret.Expiration, time.Now()); err != nil {
+		return nil, err
+	}
+
+	ret.Status = registrationStatus(ret.Expiration, time.Now(), ret.Statuses)
+
+	return ret, nil
+}
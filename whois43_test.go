@@ -0,0 +1,38 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseWhoisBlocksJoinsContinuationLines(t *testing.T) {
+	blocks := parseWhoisBlocks(readTestdata(t, "multi.cz.txt"))
+
+	if len(blocks) != 4 {
+		t.Fatalf("parseWhoisBlocks = %d blocks, want 4", len(blocks))
+	}
+	if want := []string{"Milesovska 1136/5 Praha 3", "CZ"}; !slices.Equal(blocks[1].values["address"], want) {
+		t.Errorf("address = %q, want %q", blocks[1].values["address"], want)
+	}
+	if want := []string{"a.ns.multi.cz (194.0.12.1, 2001:678:f::1)", "b.ns.multi.cz"}; !slices.Equal(blocks[3].values["nserver"], want) {
+		t.Errorf("nserver = %q, want %q", blocks[3].values["nserver"], want)
+	}
+	if last := blocks[1].fields[1]; last != (Field{"address", "Milesovska 1136/5 Praha 3"}) {
+		t.Errorf("fields[1] = %v, want the joined address", last)
+	}
+}
+
+func TestProcessWhoisResultMultiLineValues(t *testing.T) {
+	result, err := processWhoisResult("multi.cz", readTestdata(t, "multi.cz.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the nsset the domain refers to counts.
+	if want := []string{"a.ns.multi.cz", "b.ns.multi.cz"}; !slices.Equal(result.Nameservers, want) {
+		t.Errorf("Nameservers = %q, want %q", result.Nameservers, want)
+	}
+	if want := []string{"Sponsoring registrar change forbidden"}; !slices.Equal(result.Statuses, want) {
+		t.Errorf("Statuses = %q, want %q", result.Statuses, want)
+	}
+}